@@ -0,0 +1,213 @@
+package le_go
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errTooManyWrites = errors.New("le_go: too many concurrent writes")
+	errBufferFull    = errors.New("le_go: too many bytes buffered by concurrent writes")
+)
+
+// asyncWriter admits a limited number of concurrent write goroutines,
+// holding up to maxBytes bytes of messages if it is set.
+// released is signaled whenever a write goroutine finishes
+type asyncWriter struct {
+	mu       sync.Mutex
+	released *sync.Cond
+	limit    int
+	inFlight int
+
+	maxBytes int
+	bytes    int
+	policy   OverflowPolicy
+}
+
+func newAsyncWriter() *asyncWriter {
+	a := &asyncWriter{}
+	a.released = sync.NewCond(&a.mu)
+
+	return a
+}
+
+// acquire admits a write goroutine for a message of size bytes unless the
+// limit is reached or the message doesn't fit in maxBytes, in which case it
+// blocks with the Block policy. A message is always admitted if no other is
+// in flight. enabled is false if writes aren't made from goroutines
+func (a *asyncWriter) acquire(size int) (enabled bool, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for {
+		if a.limit <= 0 {
+			return false, nil
+		}
+
+		if a.maxBytes <= 0 || a.inFlight == 0 || a.bytes+size <= a.maxBytes {
+			break
+		}
+
+		if a.policy != Block {
+			return true, errBufferFull
+		}
+
+		a.released.Wait()
+	}
+
+	if a.inFlight >= a.limit {
+		return true, errTooManyWrites
+	}
+	a.inFlight++
+	a.bytes += size
+
+	return true, nil
+}
+
+// release marks an admitted write goroutine for a message
+// of size bytes as finished
+func (a *asyncWriter) release(size int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.inFlight--
+	a.bytes -= size
+	a.released.Broadcast()
+}
+
+// wait blocks until there are no write goroutines
+func (a *asyncWriter) wait() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for a.inFlight > 0 {
+		a.released.Wait()
+	}
+}
+
+// waitTimeout blocks until there are no write goroutines or until d elapses,
+// it returns the number of write goroutines which are still running
+func (a *asyncWriter) waitTimeout(d time.Duration) int {
+	expired := false
+
+	timer := time.AfterFunc(d, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		expired = true
+		a.released.Broadcast()
+	})
+	defer timer.Stop()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for a.inFlight > 0 && !expired {
+		a.released.Wait()
+	}
+
+	return a.inFlight
+}
+
+// InFlight returns the number of goroutines writing messages,
+// see SetConcurrentWrites()
+func (logger *Logger) InFlight() int {
+	logger.mu.Lock()
+	a := logger.async
+	logger.mu.Unlock()
+
+	if a == nil {
+		return 0
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.inFlight
+}
+
+// SetConcurrentWrites makes Output write every message from a goroutine,
+// with up to n goroutines at once. Messages logged while n writes are in
+// progress are dropped and Output returns an error.
+// The limit can be changed at any time, n <= 0 makes Output blocking again.
+// Ordered mode takes precedence.
+func (logger *Logger) SetConcurrentWrites(n int) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.async == nil {
+		if n <= 0 {
+			return
+		}
+
+		logger.async = newAsyncWriter()
+	}
+
+	logger.async.mu.Lock()
+	defer logger.async.mu.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	logger.async.limit = n
+
+	// the writes blocked on the buffered bytes write synchronously if disabled
+	logger.async.released.Broadcast()
+}
+
+// SetMaxBufferedBytes bounds the memory held by the messages written from
+// goroutines, see SetConcurrentWrites(), to n bytes. A message which doesn't
+// fit waits for room with the Block policy, otherwise it is dropped and
+// Output returns an error, DropOldest is treated as DropNewest since the
+// messages being written can't be discarded.
+// A message larger than n is written once no other message is in flight.
+// n <= 0 removes the bound.
+func (logger *Logger) SetMaxBufferedBytes(n int, policy OverflowPolicy) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.async == nil {
+		if n <= 0 {
+			return
+		}
+
+		logger.async = newAsyncWriter()
+	}
+
+	logger.async.mu.Lock()
+	defer logger.async.mu.Unlock()
+
+	logger.async.maxBytes = n
+	logger.async.policy = policy
+	logger.async.released.Broadcast()
+}
+
+// outputAsync writes s from a goroutine if a has room for it.
+// It returns false if writes aren't made from goroutines
+func (logger *Logger) outputAsync(a *asyncWriter, severity Severity, header, s string) (bool, error) {
+	size := len(header) + len(s)
+
+	enabled, err := a.acquire(size)
+	if !enabled {
+		return false, nil
+	}
+
+	if err != nil {
+		atomic.AddUint64(&logger.dropped, 1)
+		return true, err
+	}
+
+	go func() {
+		defer a.release(size)
+
+		if err := logger.output(context.Background(), severity, header, s); err != nil && err != errMessageTooLarge {
+			atomic.AddUint64(&logger.dropped, 1)
+			logger.errorf("dropped a message: %v", err)
+		}
+	}()
+
+	return true, nil
+}
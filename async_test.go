@@ -0,0 +1,228 @@
+package le_go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetConcurrentWritesLimitsWrites(t *testing.T) {
+	conn := &fakeConnection{delay: 50 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetConcurrentWrites(3)
+
+	for i := 0; i < 3; i++ {
+		if err := le.Print(i); err != nil {
+			t.Fatalf("expected message %d to be admitted, got %v", i, err)
+		}
+	}
+
+	if err := le.Print(3); err != errTooManyWrites {
+		t.Fatalf("expected errTooManyWrites, got %v", err)
+	}
+
+	le.Flush()
+
+	if len(conn.Written()) != 3 {
+		t.Fatalf("expected 3 writes, got %d", len(conn.Written()))
+	}
+
+	le.SetConcurrentWrites(1)
+
+	if err := le.Print(4); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := le.Print(5); err != errTooManyWrites {
+		t.Fatalf("expected the smaller limit to be applied, got %v", err)
+	}
+
+	le.Flush()
+
+	if len(conn.Written()) != 4 {
+		t.Fatalf("expected 4 writes, got %d", len(conn.Written()))
+	}
+
+	if dropped := le.Stats().Dropped; dropped != 2 {
+		t.Fatalf("expected 2 dropped messages, got %d", dropped)
+	}
+}
+
+func TestSetConcurrentWritesDisable(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetConcurrentWrites(1)
+	le.SetConcurrentWrites(0)
+
+	le.Print("1")
+
+	// blocking writes are done once Output returns
+	if len(conn.Written()) != 1 {
+		t.Fatalf("expected 1 write, got %d", len(conn.Written()))
+	}
+}
+
+func TestCloseWaitsForConcurrentWrites(t *testing.T) {
+	conn := &fakeConnection{delay: 20 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+
+	le.SetConcurrentWrites(5)
+
+	for i := 0; i < 5; i++ {
+		le.Print(i)
+	}
+
+	le.Close()
+
+	if len(conn.Written()) != 5 {
+		t.Fatalf("expected 5 writes, got %d", len(conn.Written()))
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	conn := &fakeConnection{delay: 20 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	if le.InFlight() != 0 {
+		t.Fatal("expected no writes in flight")
+	}
+
+	le.SetConcurrentWrites(10)
+
+	for i := 0; i < 10; i++ {
+		le.Print(i)
+	}
+
+	if n := le.InFlight(); n <= 1 {
+		t.Fatalf("expected more than 1 write in flight, got %d", n)
+	}
+
+	le.Flush()
+
+	if n := le.InFlight(); n != 0 {
+		t.Fatalf("expected no writes in flight after Flush, got %d", n)
+	}
+}
+
+func TestFatalFlushesBeforeExit(t *testing.T) {
+	conn := &fakeConnection{delay: 20 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetConcurrentWrites(10)
+
+	written := -1
+	le.SetExitFunc(func(code int) {
+		written = len(conn.Written())
+	}, nil)
+
+	le.Print("1")
+	le.Print("2")
+	le.Fatal("fatal")
+
+	if written != 3 {
+		t.Fatalf("expected 3 writes before exiting, got %d", written)
+	}
+}
+
+func TestSyncWaitsForConcurrentWrites(t *testing.T) {
+	conn := &fakeConnection{delay: 20 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetConcurrentWrites(5)
+
+	for i := 0; i < 5; i++ {
+		le.Print(i)
+	}
+
+	if err := le.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conn.Written()) != 5 {
+		t.Fatalf("expected 5 writes, got %d", len(conn.Written()))
+	}
+}
+
+func TestSetMaxBufferedBytes(t *testing.T) {
+	conn := &fakeConnection{delay: 50 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	// every message holds 11 bytes, its 10 bytes and the header space
+	le.SetConcurrentWrites(10)
+	le.SetMaxBufferedBytes(25, DropNewest)
+
+	for i := 0; i < 2; i++ {
+		if err := le.Print("0123456789"); err != nil {
+			t.Fatalf("expected message %d to be admitted, got %v", i, err)
+		}
+	}
+
+	if err := le.Print("0123456789"); err != errBufferFull {
+		t.Fatalf("expected errBufferFull, got %v", err)
+	}
+
+	le.Flush()
+
+	if len(conn.Written()) != 2 {
+		t.Fatalf("expected 2 writes, got %d", len(conn.Written()))
+	}
+
+	le.SetMaxBufferedBytes(11, Block)
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := le.Print("0123456789"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected the second message to wait for the first, took %v", elapsed)
+	}
+
+	le.Flush()
+
+	if len(conn.Written()) != 4 {
+		t.Fatalf("expected 4 writes, got %d", len(conn.Written()))
+	}
+}
+
+func TestPendingAndWriteStartedAt(t *testing.T) {
+	conn := &fakeConnection{delay: 100 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetConcurrentWrites(2)
+
+	le.Print("1")
+
+	if pending := le.Pending(); pending != 1 {
+		t.Fatalf("expected 1 pending message, got %d", pending)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for le.WriteStartedAt().IsZero() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if started := le.WriteStartedAt(); started.IsZero() || time.Since(started) > time.Second {
+		t.Fatalf("expected the start of the write in progress, got %v", started)
+	}
+
+	le.Flush()
+
+	if pending := le.Pending(); pending != 0 {
+		t.Fatalf("expected no pending messages, got %d", pending)
+	}
+
+	if started := le.WriteStartedAt(); !started.IsZero() {
+		t.Fatalf("expected no write in progress, got %v", started)
+	}
+}
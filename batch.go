@@ -0,0 +1,143 @@
+package le_go
+
+import "time"
+
+// batch accumulates formatted lines which are written to the TCP
+// connection in a single write
+type batch struct {
+	maxBytes    int
+	maxMessages int
+	maxDelay    time.Duration
+
+	buf      []byte
+	messages int
+
+	// timer flushes the batch once maxDelay elapsed since its first line
+	timer *time.Timer
+}
+
+// add appends the lines of the given number of messages to the batch
+func (b *batch) add(lines []byte, messages int) {
+	b.buf = append(b.buf, lines...)
+	b.messages += messages
+}
+
+// full returns if the batch reached one of its limits
+func (b *batch) full() bool {
+	return (b.maxBytes > 0 && len(b.buf) >= b.maxBytes) ||
+		(b.maxMessages > 0 && b.messages >= b.maxMessages)
+}
+
+// take returns the batched lines and the number of their messages,
+// and empties the batch
+func (b *batch) take() ([]byte, int) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	buf, messages := b.buf, b.messages
+	b.buf = nil
+	b.messages = 0
+
+	return buf, messages
+}
+
+// SetBatching enables batching mode, in which messages are accumulated and
+// written to logentries.com in a single write once the batch holds maxBytes
+// bytes or maxMessages messages, or maxDelay after its first message.
+// A limit of 0 is ignored, if all of them are 0 batching is disabled.
+// Flush and Close write the batched messages immediately.
+func (logger *Logger) SetBatching(maxBytes, maxMessages int, maxDelay time.Duration) {
+	logger.mu.Lock()
+
+	if maxBytes <= 0 && maxMessages <= 0 && maxDelay <= 0 {
+		if logger.batch == nil {
+			logger.mu.Unlock()
+			return
+		}
+
+		// the messages batched so far are written before disabling batching
+		logger.flushBatchUnlock(time.Time{})
+
+		logger.mu.Lock()
+		logger.batch = nil
+		logger.mu.Unlock()
+		return
+	}
+
+	if logger.batch == nil {
+		logger.batch = &batch{}
+	}
+
+	logger.batch.maxBytes = maxBytes
+	logger.batch.maxMessages = maxMessages
+	logger.batch.maxDelay = maxDelay
+	logger.mu.Unlock()
+}
+
+// SetFlushSeverity writes the batch as soon as a message of severity sev,
+// or more severe, is added to it in batching mode, so that e.g. errors
+// don't wait for the batch delay. The messages batched before it are
+// written along, in order. The less severe messages are batched as usual.
+// A negative severity disables it, which is the default.
+func (logger *Logger) SetFlushSeverity(sev Severity) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.flushSeverity = sev
+	logger.flushOnSeverity = sev >= 0
+}
+
+// flushes returns if a message of severity sev writes the batch,
+// the logger lock must be held
+func (logger *Logger) flushes(sev Severity) bool {
+	return logger.flushOnSeverity && sev <= logger.flushSeverity
+}
+
+// batchUnlock adds the lines of the given number of messages to the batch
+// and writes the batch if it is full or flush is set, n is returned as the
+// number of bytes written.
+// It must be called with the logger lock held, it releases the lock.
+func (logger *Logger) batchUnlock(lines []byte, messages, n int, flush bool, deadline time.Time) (int, error) {
+	b := logger.batch
+
+	if len(b.buf) == 0 && b.maxDelay > 0 {
+		b.timer = time.AfterFunc(b.maxDelay, logger.flushBatch)
+	}
+
+	b.add(lines, messages)
+
+	if !flush && !b.full() {
+		logger.mu.Unlock()
+		return n, nil
+	}
+
+	if _, err := logger.flushBatchUnlock(deadline); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// flushBatch writes the batched messages
+func (logger *Logger) flushBatch() {
+	logger.mu.Lock()
+
+	if _, err := logger.flushBatchUnlock(time.Time{}); err != nil {
+		logger.errorf("dropped batched messages: %v", err)
+	}
+}
+
+// flushBatchUnlock writes the batched messages.
+// It must be called with the logger lock held, it releases the lock.
+func (logger *Logger) flushBatchUnlock(deadline time.Time) (int, error) {
+	if logger.batch == nil || len(logger.batch.buf) == 0 {
+		logger.mu.Unlock()
+		return 0, nil
+	}
+
+	buf, messages := logger.batch.take()
+
+	return logger.sendUnlock(buf, messages, deadline)
+}
@@ -0,0 +1,146 @@
+package le_go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchingWritesFullBatch(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetBatching(0, 3, 0)
+
+	le.Print("1")
+	le.Print("2")
+
+	if len(conn.Written()) != 0 {
+		t.Fatalf("expected no writes before the batch is full, got %q", conn.Written())
+	}
+
+	le.Print("3")
+
+	writes := conn.Written()
+	if len(writes) != 1 {
+		t.Fatalf("expected 1 write, got %d", len(writes))
+	}
+
+	if want := "myToken  1\nmyToken  2\nmyToken  3\n"; string(writes[0]) != want {
+		t.Fatalf("expected %q, got %q", want, writes[0])
+	}
+}
+
+func TestBatchingMaxBytes(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	// every line is 11 bytes
+	le.SetBatching(20, 0, 0)
+
+	le.Print("1")
+	if len(conn.Written()) != 0 {
+		t.Fatal("expected no writes before the batch is full")
+	}
+
+	le.Print("2")
+	if len(conn.Written()) != 1 {
+		t.Fatalf("expected 1 write, got %d", len(conn.Written()))
+	}
+}
+
+func TestBatchingMaxDelay(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetBatching(0, 100, 10*time.Millisecond)
+
+	le.Print("1")
+
+	for i := 0; i < 100 && len(conn.Written()) == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken  1\n" {
+		t.Fatalf("expected the batch to be written after the delay, got %q", writes)
+	}
+}
+
+func TestFlushWritesBatch(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetBatching(0, 100, 0)
+
+	le.Print("1")
+	le.Print("2")
+	le.Flush()
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken  1\nmyToken  2\n" {
+		t.Fatalf("expected Flush to write the batch, got %q", writes)
+	}
+}
+
+func TestCloseWritesBatch(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+
+	le.SetBatching(0, 100, 0)
+
+	le.Print("1")
+	le.Close()
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken  1\n" {
+		t.Fatalf("expected Close to write the batch, got %q", writes)
+	}
+}
+
+func TestCloseStopsBatchTimer(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+
+	le.SetBatching(0, 100, 20*time.Millisecond)
+
+	le.Print("1")
+	le.Close()
+
+	if le.batch.timer != nil {
+		t.Fatal("expected Close to stop the batch timer")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken  1\n" {
+		t.Fatalf("expected the batch to be written once, got %q", writes)
+	}
+}
+
+func TestSetFlushSeverity(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetBatching(0, 100, time.Hour)
+	le.SetFlushSeverity(SeverityError)
+
+	le.Print("1")
+
+	if writes := conn.Written(); len(writes) != 0 {
+		t.Fatalf("expected the info message to be batched, got %q", writes)
+	}
+
+	le.OutputSeverity(1, SeverityError, "2")
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken  1\nmyToken  2\n" {
+		t.Fatalf("expected the error message to write the batch, got %q", writes)
+	}
+
+	le.Print("3")
+
+	if writes := conn.Written(); len(writes) != 1 {
+		t.Fatalf("expected the info message to be batched, got %q", writes)
+	}
+}
@@ -0,0 +1,58 @@
+package le_go
+
+import (
+	"errors"
+	"time"
+)
+
+var errCircuitOpen = errors.New("le_go: circuit breaker is open after repeated connection failures")
+
+// breaker stops dialing for a cooldown after consecutive connection failures
+type breaker struct {
+	maxFailures int
+	cooldown    time.Duration
+
+	failures  int
+	openUntil time.Time
+}
+
+// allow returns if a connection can be attempted at now
+func (b *breaker) allow(now time.Time) bool {
+	return !now.Before(b.openUntil)
+}
+
+// open returns if the breaker suppresses the connections at now
+func (b *breaker) open(now time.Time) bool {
+	return !b.allow(now)
+}
+
+// record counts the outcome of a connection attempt at now,
+// the breaker opens once maxFailures attempts failed in a row
+func (b *breaker) record(now time.Time, err error) {
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	if b.failures++; b.failures >= b.maxFailures {
+		b.failures = 0
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
+
+// SetCircuitBreaker stops reconnecting for cooldown once maxFailures
+// connection attempts failed in a row, the writes made meanwhile fail
+// without dialing and are dropped unless a spool, retry queue or fallback
+// takes them. Stats reports if the breaker is open.
+// maxFailures <= 0 disables the breaker.
+func (logger *Logger) SetCircuitBreaker(maxFailures int, cooldown time.Duration) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if maxFailures <= 0 {
+		logger.breaker = nil
+		return
+	}
+
+	logger.breaker = &breaker{maxFailures: maxFailures, cooldown: cooldown}
+}
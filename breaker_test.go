@@ -0,0 +1,59 @@
+package le_go
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	dials := 0
+	clock := newFakeClock()
+	le := Logger{token: "myToken", clock: clock}
+	le.SetDialFunc(func() (net.Conn, error) {
+		dials++
+		return nil, errors.New("connection refused")
+	})
+	defer le.Close()
+
+	le.SetCircuitBreaker(3, time.Minute)
+
+	for dials < 3 {
+		if err := le.Print("test"); err == nil {
+			t.Fatal("expected the write to fail")
+		}
+	}
+
+	if !le.Stats().BreakerOpen {
+		t.Fatal("expected the breaker to open")
+	}
+
+	dropped := le.Stats().Dropped
+
+	for i := 0; i < 5; i++ {
+		if err := le.Print("test"); err != errCircuitOpen {
+			t.Fatalf("expected errCircuitOpen, got %v", err)
+		}
+	}
+
+	if dials != 3 {
+		t.Fatalf("expected no dials while the breaker is open, got %d", dials)
+	}
+
+	if n := le.Stats().Dropped - dropped; n != 5 {
+		t.Fatalf("expected 5 dropped messages, got %d", n)
+	}
+
+	clock.Advance(time.Minute)
+
+	if le.Stats().BreakerOpen {
+		t.Fatal("expected the breaker to close after the cooldown")
+	}
+
+	le.Print("test")
+
+	if dials == 3 {
+		t.Fatal("expected a dial after the cooldown")
+	}
+}
@@ -0,0 +1,34 @@
+package le_go
+
+import "time"
+
+// clock tells the time and waits, it is replaced in tests
+// to run the time-dependent code without sleeping
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the clock of the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// now returns the current time of the logger clock
+func (logger *Logger) now() time.Time {
+	if logger.clock == nil {
+		return time.Now()
+	}
+
+	return logger.clock.Now()
+}
+
+// after is same as time.After() but waits on the logger clock
+func (logger *Logger) after(d time.Duration) <-chan time.Time {
+	if logger.clock == nil {
+		return time.After(d)
+	}
+
+	return logger.clock.After(d)
+}
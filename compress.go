@@ -0,0 +1,63 @@
+package le_go
+
+import (
+	"compress/gzip"
+	"net"
+	"sync"
+)
+
+// gzipConn is a net.Conn which compresses the written lines into a gzip
+// stream, flushed after every write so no line waits in the compressor
+type gzipConn struct {
+	net.Conn
+
+	mu sync.Mutex
+	gz *gzip.Writer
+}
+
+func newGzipConn(conn net.Conn) *gzipConn {
+	return &gzipConn{Conn: conn, gz: gzip.NewWriter(conn)}
+}
+
+// Write compresses b and flushes it to the connection
+func (c *gzipConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.gz.Write(b); err != nil {
+		return 0, err
+	}
+
+	if err := c.gz.Flush(); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Close ends the gzip stream and closes the connection
+func (c *gzipConn) Close() error {
+	c.mu.Lock()
+	err := c.gz.Close()
+	c.mu.Unlock()
+
+	if closeErr := c.Conn.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// SetCompression enables compressing the lines written to the connections
+// opened by the logger into a gzip stream, to save bandwidth on metered
+// links. Every write is flushed, so every message or batch is sent right
+// away. It takes effect on the next dial and doesn't apply to connections
+// supplied by the caller.
+// The endpoint must accept gzip compressed ingestion, which the
+// Logentries TCP endpoints don't, so it is disabled by default.
+func (logger *Logger) SetCompression(enabled bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.compress = enabled
+}
@@ -0,0 +1,48 @@
+package le_go
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSetCompression(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{token: "myToken", dial: conn.redial()}
+
+	le.SetCompression(true)
+
+	le.Print("1")
+	le.Print("2")
+
+	// the lines are flushed before the stream ends
+	gz, err := gzip.NewReader(bytes.NewReader(bytes.Join(conn.Written(), nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(gz)
+	for _, want := range []string{"myToken  1\n", "myToken  2\n"} {
+		if line, err := r.ReadString('\n'); err != nil || line != want {
+			t.Fatalf("expected %q, got %q, %v", want, line, err)
+		}
+	}
+
+	le.Close()
+
+	gz, err = gzip.NewReader(bytes.NewReader(bytes.Join(conn.Written(), nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "myToken  1\nmyToken  2\n" {
+		t.Fatalf("unexpected decompressed lines %q", b)
+	}
+}
@@ -0,0 +1,135 @@
+package le_go
+
+import (
+	"context"
+	"fmt"
+)
+
+// loggerKey is the context key of the Logger, see ContextWithLogger()
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx which carries logger,
+// so it doesn't have to be passed around, see FromContext()
+func ContextWithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the Logger carried by ctx, if it doesn't carry one
+// it returns a Logger which discards all the messages
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*Logger); ok && logger != nil {
+		return logger
+	}
+
+	return NewNop()
+}
+
+// OutputContext is same as Output() but gives up waiting for the logger
+// when ctx is done, the deadline of ctx is used as the write deadline.
+// Nothing is written if ctx is already done.
+// In ordered mode ctx only bounds the wait for room in the queue, and
+// with concurrent writes the message is written from a goroutine, in both
+// cases the message is written in the background without the deadline.
+func (logger *Logger) OutputContext(ctx context.Context, calldepth int, s string) error {
+	return logger.outputSeverity(ctx, calldepth+1, logger.severityOf(s), s, false)
+}
+
+// PrintContext is same as Print() but gives up when ctx is done
+func (logger *Logger) PrintContext(ctx context.Context, v ...interface{}) error {
+	return logger.OutputContext(ctx, 2, fmt.Sprint(v...))
+}
+
+// PrintfContext is same as Printf() but gives up when ctx is done
+func (logger *Logger) PrintfContext(ctx context.Context, format string, v ...interface{}) error {
+	return logger.OutputContext(ctx, 2, fmt.Sprintf(format, v...))
+}
+
+// PrintlnContext is same as Println() but gives up when ctx is done
+func (logger *Logger) PrintlnContext(ctx context.Context, v ...interface{}) error {
+	return logger.OutputContext(ctx, 2, fmt.Sprintln(v...))
+}
+
+// SetTraceExtractor sets the function returning the trace and span IDs of
+// the span of a context, which are added to the messages logged with the
+// context-aware methods as trace_id and span_id fields, so the logs can be
+// correlated with the traces. The IDs which are empty are left out,
+// see the leotel module for OpenTelemetry. nil removes the extractor.
+func (logger *Logger) SetTraceExtractor(extract func(ctx context.Context) (traceID, spanID string)) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.traceExtractor = extract
+}
+
+// SetContextExtractor sets the function returning key-value pairs of a
+// context, e.g. request-scoped metadata such as the tenant or the user,
+// which are added to the messages logged with the context-aware methods as
+// key=value pairs, or as fields in the JSON format. Keys which aren't
+// strings and values are formatted with fmt.Sprint, a key without a value
+// gets the !MISSING value. nil removes the extractor.
+func (logger *Logger) SetContextExtractor(extract func(ctx context.Context) []interface{}) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.contextExtractor = extract
+}
+
+// contextFields returns the fields extracted from ctx,
+// the logger lock must be held
+func (logger *Logger) contextFields(ctx context.Context) []tag {
+	var fields []tag
+
+	if logger.traceExtractor != nil {
+		traceID, spanID := logger.traceExtractor(ctx)
+		if traceID != "" {
+			fields = append(fields, tag{key: "trace_id", value: traceID})
+		}
+		if spanID != "" {
+			fields = append(fields, tag{key: "span_id", value: spanID})
+		}
+	}
+
+	if logger.contextExtractor != nil {
+		pairs := logger.contextExtractor(ctx)
+
+		for i := 0; i < len(pairs); i += 2 {
+			value := "!MISSING"
+			if i+1 < len(pairs) {
+				value = fmt.Sprint(pairs[i+1])
+			}
+
+			fields = append(fields, tag{key: fmt.Sprint(pairs[i]), value: value})
+		}
+	}
+
+	return fields
+}
+
+// lockContext acquires the logger lock unless ctx is done first
+func (logger *Logger) lockContext(ctx context.Context) error {
+	// the lock is taken directly if ctx can't be done
+	if ctx.Done() == nil {
+		logger.mu.Lock()
+		return nil
+	}
+
+	locked := make(chan struct{})
+
+	go func() {
+		logger.mu.Lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		// release the lock once the goroutine acquires it
+		go func() {
+			<-locked
+			logger.mu.Unlock()
+		}()
+
+		return ctx.Err()
+	}
+}
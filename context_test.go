@@ -0,0 +1,236 @@
+package le_go
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPrintContextCancelledDoesNotWrite(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := le.PrintContext(ctx, "test"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if len(conn.Written()) != 0 {
+		t.Fatal("expected no writes")
+	}
+}
+
+func TestPrintContextGivesUpWaitingForLock(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+
+	le.mu.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := le.PrintContext(ctx, "test"); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	le.mu.Unlock()
+
+	if err := le.PrintContext(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conn.Written()) != 1 {
+		t.Fatal("expected 1 write")
+	}
+}
+
+// deadlineConnection records the write deadline in effect for every write
+type deadlineConnection struct {
+	fakeConnection
+	deadline  time.Time
+	deadlines []time.Time
+}
+
+func (c *deadlineConnection) SetWriteDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func (c *deadlineConnection) Write(b []byte) (int, error) {
+	c.deadlines = append(c.deadlines, c.deadline)
+	return c.fakeConnection.Write(b)
+}
+
+func TestPrintContextDeadlineAppliesOnlyToItsWrite(t *testing.T) {
+	conn := &deadlineConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	if err := le.PrintContext(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	le.Print("2")
+
+	if len(conn.deadlines) != 2 {
+		t.Fatalf("expected 2 writes, got %d", len(conn.deadlines))
+	}
+
+	if !conn.deadlines[0].Equal(deadline) || !conn.deadlines[1].IsZero() {
+		t.Fatalf("unexpected write deadlines %v", conn.deadlines)
+	}
+}
+
+func TestPrintContextOrderedModeGivesUpOnFullQueue(t *testing.T) {
+	le := Logger{conn: &fakeConnection{}, token: "myToken"}
+	defer le.Close()
+
+	// no worker is started so the queue fills up
+	o := newOrderedWriter()
+	le.ordered = o
+
+	for i := 0; i < orderedQueueSize; i++ {
+		o.messages <- orderedMessage{s: "test"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := le.PrintContext(ctx, "test"); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	le.ordered = nil
+}
+
+type traceKey struct{}
+
+func TestSetTraceExtractor(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetTraceExtractor(func(ctx context.Context) (string, string) {
+		traceID, _ := ctx.Value(traceKey{}).(string)
+		return traceID, ""
+	})
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	le.PrintlnContext(ctx, "traced")
+	le.PrintContext(context.Background(), "untraced")
+
+	le.SetJSONFormat(true)
+	le.PrintContext(ctx, "traced")
+
+	want := []string{
+		"myToken  traced trace_id=4bf92f3577b34da6a3ce929d0e0e4736\n",
+		"myToken  untraced\n",
+		`myToken {"severity":"INFO","message":"traced","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"}` + "\n",
+	}
+
+	writes := conn.Written()
+	if len(writes) != len(want) {
+		t.Fatalf("expected %d writes, got %q", len(want), writes)
+	}
+
+	for i := range want {
+		if string(writes[i]) != want[i] {
+			t.Errorf("expected %q, got %q", want[i], writes[i])
+		}
+	}
+}
+
+type tenantKey struct{}
+
+func TestSetContextExtractor(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetContextExtractor(func(ctx context.Context) []interface{} {
+		tenant, ok := ctx.Value(tenantKey{}).(string)
+		if !ok {
+			return nil
+		}
+
+		return []interface{}{"tenant", tenant, "user", 42, "orphan"}
+	})
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme corp")
+
+	le.PrintContext(ctx, "test")
+	le.PrintContext(context.Background(), "test")
+
+	want := []string{
+		`myToken  test tenant="acme corp" user=42 orphan=!MISSING` + "\n",
+		"myToken  test\n",
+	}
+
+	writes := conn.Written()
+	if len(writes) != len(want) {
+		t.Fatalf("expected %d writes, got %q", len(want), writes)
+	}
+
+	for i := range want {
+		if string(writes[i]) != want[i] {
+			t.Errorf("expected %q, got %q", want[i], writes[i])
+		}
+	}
+}
+
+func TestContextWithLogger(t *testing.T) {
+	le := &Logger{conn: &fakeConnection{}, token: "myToken"}
+	defer le.Close()
+
+	if got := FromContext(ContextWithLogger(context.Background(), le)); got != le {
+		t.Fatalf("expected the logger of the context, got %p", got)
+	}
+
+	nop := FromContext(context.Background())
+	if nop == nil || !nop.nop {
+		t.Fatal("expected a no-op logger without a logger in the context")
+	}
+
+	if err := nop.Print("test"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrintContextAppliesOutputPipeline(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", clock: newFakeClock()}
+	defer le.Close()
+
+	le.SetHeaderSeverity(SeverityBeforeTimestamp, false)
+	le.SetSeverityPrefixes(map[string]Severity{"ERROR:": SeverityError})
+	le.SetRateLimit(1, 1)
+
+	if err := le.PrintContext(context.Background(), "ERROR: boom"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := le.PrintContext(context.Background(), "limited"); err != errRateLimited {
+		t.Fatalf("expected errRateLimited, got %v", err)
+	}
+
+	le.SetRateLimit(0, 0)
+	le.SetSampling(0, SeverityError)
+
+	if err := le.PrintContext(context.Background(), "sampled out"); err != nil {
+		t.Fatal(err)
+	}
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken  ERROR ERROR: boom\n" {
+		t.Fatalf("expected only the error to be written, got %q", writes)
+	}
+
+	if dropped := le.Stats().Dropped; dropped != 1 {
+		t.Fatalf("expected 1 dropped message, got %d", dropped)
+	}
+}
@@ -0,0 +1,119 @@
+package le_go
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// dedup suppresses the consecutive repeats of a message within a window
+type dedup struct {
+	window time.Duration
+
+	// last is the message which opened the current window and repeated
+	// the number of its repeats suppressed since, gen identifies the window
+	last     string
+	severity Severity
+	repeated int
+	gen      int
+	timer    *time.Timer
+}
+
+// check returns if s logged with severity repeats the last message within
+// the window and must be suppressed. Otherwise it opens a new window for s
+// and returns the summary of the repeats of the previous message, if any,
+// end is called with the generation of the window once it elapses.
+func (d *dedup) check(severity Severity, s string, end func(gen int)) (string, bool) {
+	if d.timer != nil && s == d.last && severity == d.severity {
+		d.repeated++
+		return "", true
+	}
+
+	summary := d.take()
+
+	d.last, d.severity = s, severity
+	d.gen++
+
+	gen := d.gen
+	d.timer = time.AfterFunc(d.window, func() { end(gen) })
+
+	return summary, false
+}
+
+// take ends the current window and returns the summary of its repeats,
+// which is empty if there weren't any
+func (d *dedup) take() string {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+
+	repeated := d.repeated
+	d.last, d.repeated = "", 0
+
+	if repeated == 0 {
+		return ""
+	}
+
+	return "last message repeated " + strconv.Itoa(repeated) + " times"
+}
+
+// SetDedup enables suppressing the consecutive repeats of a message,
+// logged with the same severity, within window after its first occurrence.
+// The number of repeats is logged as "last message repeated N times" once
+// the window elapses or a different message is logged.
+// A window <= 0 disables it, the pending summary is written first.
+func (logger *Logger) SetDedup(window time.Duration) {
+	logger.flushDedup(-1)
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if window <= 0 {
+		logger.dedup = nil
+		return
+	}
+
+	if logger.dedup == nil {
+		logger.dedup = &dedup{}
+	}
+	logger.dedup.window = window
+}
+
+// flushDedup ends the dedup window gen, or the current one if gen is -1,
+// and writes the summary of its repeats
+func (logger *Logger) flushDedup(gen int) {
+	logger.mu.Lock()
+
+	d := logger.dedup
+	if d == nil || (gen != -1 && gen != d.gen) {
+		logger.mu.Unlock()
+		return
+	}
+
+	severity := d.severity
+	summary := d.take()
+	if summary == "" {
+		logger.mu.Unlock()
+		return
+	}
+
+	o, a, closed := logger.ordered, logger.async, logger.closed
+	header, s, err := logger.entry(severity, summary, "", 0, "", nil)
+	logger.mu.Unlock()
+
+	if err != nil {
+		logger.errorf("dropped a repeated message summary: %v", err)
+		return
+	}
+
+	logger.writeDedupSummary(o, a, closed, severity, header, s)
+}
+
+// writeDedupSummary writes the summary s of repeated messages logged with
+// severity, the errors are reported since the summary isn't written by a caller
+func (logger *Logger) writeDedupSummary(o *orderedWriter, a *asyncWriter, closed bool, severity Severity, header, s string) {
+	if err := logger.dispatch(context.Background(), o, a, closed, severity, header, s); err != nil {
+		logger.errorf("dropped a repeated message summary: %v", err)
+	}
+}
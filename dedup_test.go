@@ -0,0 +1,79 @@
+package le_go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetDedup(time.Hour)
+
+	for i := 0; i < 5; i++ {
+		le.Print("failed")
+	}
+	le.Print("recovered")
+
+	want := []string{
+		"myToken  failed\n",
+		"myToken  last message repeated 4 times\n",
+		"myToken  recovered\n",
+	}
+
+	writes := conn.Written()
+	if len(writes) != len(want) {
+		t.Fatalf("expected %d writes, got %q", len(want), writes)
+	}
+
+	for i := range want {
+		if string(writes[i]) != want[i] {
+			t.Errorf("expected %q, got %q", want[i], writes[i])
+		}
+	}
+}
+
+func TestDedupWindowElapses(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetDedup(10 * time.Millisecond)
+
+	le.Print("failed")
+	le.Print("failed")
+
+	for i := 0; i < 100 && len(conn.Written()) < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	writes := conn.Written()
+	if len(writes) != 2 || string(writes[1]) != "myToken  last message repeated 1 times\n" {
+		t.Fatalf("expected the summary once the window elapsed, got %q", writes)
+	}
+
+	// the window is over so the message is logged again
+	le.Print("failed")
+
+	if writes := conn.Written(); len(writes) != 3 || string(writes[2]) != "myToken  failed\n" {
+		t.Fatalf("expected the message after the window, got %q", writes)
+	}
+}
+
+func TestFlushWritesDedupSummary(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetDedup(time.Hour)
+
+	le.Print("failed")
+	le.Print("failed")
+	le.Flush()
+
+	if writes := conn.Written(); len(writes) != 2 || string(writes[1]) != "myToken  last message repeated 1 times\n" {
+		t.Fatalf("expected Flush to write the summary, got %q", writes)
+	}
+}
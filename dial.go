@@ -0,0 +1,200 @@
+package le_go
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+const (
+	// the timeout of the connection to a single address of a host
+	// and the delay between starting the connections to its addresses
+	dialAttemptTimeout = 5 * time.Second
+	dialAttemptDelay   = 250 * time.Millisecond
+
+	// the default timeout of the TLS handshake
+	defaultHandshakeTimeout = 10 * time.Second
+)
+
+// dnsEntry holds the cached addresses of a host until expires
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dialResult is the outcome of the connection to one address
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialHost opens a TLS connection to host:port, which is resolved again
+// on every dial unless the DNS cache holds it. If the host resolves to
+// multiple addresses they are dialed concurrently, staggered by
+// dialAttemptDelay, and the first connection to succeed is used,
+// so a single unreachable address doesn't stall the connect
+func (logger *Logger) dialHost(dialer *net.Dialer, host string, config *tls.Config) (net.Conn, error) {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+
+	addrs, err := logger.resolve(hostname)
+	if err != nil || len(addrs) == 0 {
+		conn, err = dialer.Dial("tcp", host)
+	} else {
+		dialContext := logger.dialContext
+		if dialContext == nil {
+			dialContext = dialer.DialContext
+		}
+
+		conn, err = dialFirst(dialContext, addrs, port)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetNoDelay(!logger.tcpDelay)
+	}
+
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName = hostname
+	}
+
+	return logger.handshake(conn, config)
+}
+
+// handshake runs the TLS handshake over conn, it gives up after the
+// handshake timeout so a peer which never answers can't block the dial
+func (logger *Logger) handshake(conn net.Conn, config *tls.Config) (net.Conn, error) {
+	timeout := logger.handshakeTimeout
+	if timeout <= 0 {
+		timeout = defaultHandshakeTimeout
+	}
+
+	tlsConn := tls.Client(conn, config)
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return tlsConn, nil
+}
+
+// SetHandshakeTimeout sets the timeout of the TLS handshake with
+// logentries.com, which defaults to 10 seconds
+func (logger *Logger) SetHandshakeTimeout(d time.Duration) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.handshakeTimeout = d
+}
+
+// resolve returns the addresses of host from the DNS cache, or from the
+// resolver if it isn't cached or its entry expired
+func (logger *Logger) resolve(host string) ([]string, error) {
+	if entry, ok := logger.dnsCache[host]; ok && logger.now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	lookupHost := logger.lookupHost
+	if lookupHost == nil {
+		lookupHost = net.DefaultResolver.LookupHost
+	}
+
+	addrs, err := lookupHost(context.Background(), host)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger.dnsCacheTTL > 0 {
+		if logger.dnsCache == nil {
+			logger.dnsCache = make(map[string]dnsEntry)
+		}
+		logger.dnsCache[host] = dnsEntry{addrs: addrs, expires: logger.now().Add(logger.dnsCacheTTL)}
+	}
+
+	return addrs, nil
+}
+
+// SetDNSCacheTTL caches the addresses of the hosts for ttl, by default
+// the hosts are resolved again on every reconnect so the logger follows
+// the changes of their addresses. A ttl <= 0 disables the cache.
+func (logger *Logger) SetDNSCacheTTL(ttl time.Duration) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.dnsCacheTTL = ttl
+	logger.dnsCache = nil
+}
+
+// SetResolver sets the function resolving the hosts to their addresses
+// when dialing, nil means net.DefaultResolver.LookupHost
+func (logger *Logger) SetResolver(lookupHost func(ctx context.Context, host string) ([]string, error)) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.lookupHost = lookupHost
+	logger.dnsCache = nil
+}
+
+// dialFirst dials port on addrs concurrently and returns the first
+// connection which succeeds, the other connections are closed.
+// It returns the first error if all of them fail.
+func dialFirst(dialContext func(ctx context.Context, network, address string) (net.Conn, error), addrs []string, port string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	results := make(chan dialResult, len(addrs))
+	for i, addr := range addrs {
+		go func(delay time.Duration, address string) {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				results <- dialResult{err: ctx.Err()}
+				return
+			}
+
+			attemptCtx, attemptCancel := context.WithTimeout(ctx, dialAttemptTimeout)
+			defer attemptCancel()
+
+			conn, err := dialContext(attemptCtx, "tcp", address)
+			results <- dialResult{conn: conn, err: err}
+		}(time.Duration(i)*dialAttemptDelay, net.JoinHostPort(addr, port))
+	}
+
+	var firstErr error
+	for i := range addrs {
+		r := <-results
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+
+		cancel()
+
+		// the connections which succeed too late are closed
+		go func(pending int) {
+			for ; pending > 0; pending-- {
+				if r := <-results; r.conn != nil {
+					r.conn.Close()
+				}
+			}
+		}(len(addrs) - i - 1)
+
+		return r.conn, nil
+	}
+
+	cancel()
+
+	return nil, firstErr
+}
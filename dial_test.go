@@ -0,0 +1,247 @@
+package le_go
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDialHostUsesFirstReachableAddress(t *testing.T) {
+	srv, config := newTLSTestServer()
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	le.SetTLSConfig(config)
+	le.SetHost(net.JoinHostPort("example.com", port))
+
+	var lookups int
+	le.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		return []string{"192.0.2.1", "127.0.0.1"}, nil
+	}
+
+	var dialer net.Dialer
+	le.dialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		// the first address is blackholed
+		if host, _, _ := net.SplitHostPort(address); host == "192.0.2.1" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	if err := le.openConnection(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lookups != 1 {
+		t.Fatalf("expected the host to be resolved once, got %d", lookups)
+	}
+}
+
+func TestReconnectResolvesHostAgain(t *testing.T) {
+	srv, config := newTLSTestServer()
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	le.SetTLSConfig(config)
+	le.SetHost(net.JoinHostPort("example.com", port))
+
+	var lookups int
+	le.SetResolver(func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		return []string{"127.0.0.1"}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := le.openConnection(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if lookups != 2 {
+		t.Fatalf("expected the host to be resolved on every reconnect, got %d lookups", lookups)
+	}
+
+	le.SetDNSCacheTTL(time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if err := le.openConnection(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if lookups != 3 {
+		t.Fatalf("expected the cached addresses to be used, got %d lookups", lookups)
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	// the listener accepts the connections but never answers the handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	le.SetHost(l.Addr().String())
+	le.SetHandshakeTimeout(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		le.mu.Lock()
+		defer le.mu.Unlock()
+
+		done <- le.openConnection()
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected the handshake to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the handshake to time out")
+	}
+}
+
+func TestSetClientCertificates(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "le_go client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		// with TLS 1.3 the client certificate is verified after the handshake
+		MaxVersion: tls.VersionTLS12,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	le.SetTLSConfig(&tls.Config{RootCAs: roots})
+	le.SetHost(srv.Listener.Addr().String())
+
+	if err := le.openConnection(); err == nil {
+		t.Fatal("expected the connection to fail without a client certificate")
+	}
+
+	le.SetClientCertificates(tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key})
+
+	if err := le.openConnection(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetServerName(t *testing.T) {
+	srv, config := newTLSTestServer()
+	defer srv.Close()
+
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	le.SetTLSConfig(config)
+	le.SetHost(srv.Listener.Addr().String())
+
+	// the certificate of the test server is valid for example.com
+	le.SetServerName("logs.example.org")
+
+	if err := le.openConnection(); err == nil {
+		t.Fatal("expected the certificate not to match the server name")
+	}
+
+	le.SetServerName("example.com")
+
+	if err := le.openConnection(); err != nil {
+		t.Fatal(err)
+	}
+
+	if name := le.conn.(*tls.Conn).ConnectionState().ServerName; name != "example.com" {
+		t.Fatalf("expected the server name to be sent, got %q", name)
+	}
+}
+
+func TestSetTCPNoDelay(t *testing.T) {
+	srv, config := newTLSTestServer()
+	defer srv.Close()
+
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	le.SetTLSConfig(config)
+	le.SetHost(srv.Listener.Addr().String())
+
+	for _, enabled := range []bool{false, true} {
+		le.SetTCPNoDelay(enabled)
+
+		if err := le.openConnection(); err != nil {
+			t.Fatalf("TCP_NODELAY %v: %v", enabled, err)
+		}
+
+		if _, err := le.conn.Write([]byte("test\n")); err != nil {
+			t.Fatalf("TCP_NODELAY %v: %v", enabled, err)
+		}
+	}
+}
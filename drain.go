@@ -0,0 +1,54 @@
+package le_go
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// drainStep bounds every wait of Drain so it notices when ctx is canceled
+const drainStep = 10 * time.Millisecond
+
+// Drain shuts the logger down cleanly: it stops accepting messages, waits
+// until the messages pending in ordered mode and the ones written from
+// goroutines are written, then closes the logger, see Close().
+// The messages logged meanwhile are dropped and return an error.
+// If ctx is done first the logger is closed without waiting and a
+// *FlushTimeoutError reports the number of messages which weren't written.
+func (logger *Logger) Drain(ctx context.Context) error {
+	logger.mu.Lock()
+	logger.draining = true
+	o, a := logger.ordered, logger.async
+	logger.mu.Unlock()
+
+	for {
+		pending := 0
+		if o != nil {
+			pending += o.waitTimeout(drainStep)
+		}
+		if a != nil {
+			pending += a.waitTimeout(drainStep)
+		}
+
+		if pending == 0 {
+			return logger.Close()
+		}
+
+		if ctx.Err() != nil {
+			logger.close(0)
+			return &FlushTimeoutError{Pending: pending}
+		}
+	}
+}
+
+// drainingLocked returns if Drain was called, the message being logged
+// is then dropped. the logger lock must be held
+func (logger *Logger) drainingLocked() bool {
+	if !logger.draining {
+		return false
+	}
+
+	atomic.AddUint64(&logger.dropped, 1)
+
+	return true
+}
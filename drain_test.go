@@ -0,0 +1,65 @@
+package le_go
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	conn := &fakeConnection{delay: 20 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+
+	le.SetOrdered(true)
+
+	for i := 0; i < 5; i++ {
+		le.Print(i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- le.Drain(ctx) }()
+
+	// the messages logged while draining are dropped
+	time.Sleep(10 * time.Millisecond)
+	if err := le.Print("late"); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conn.Written()) != 5 {
+		t.Fatalf("expected the 5 pending messages to be written, got %d", len(conn.Written()))
+	}
+
+	if !conn.closed {
+		t.Fatal("expected the connection to be closed")
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	conn := &fakeConnection{delay: 50 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+
+	le.SetOrdered(true)
+
+	for i := 0; i < 10; i++ {
+		le.Print(i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err, ok := le.Drain(ctx).(*FlushTimeoutError)
+	if !ok || err.Pending == 0 {
+		t.Fatalf("expected a *FlushTimeoutError, got %v", err)
+	}
+
+	if !conn.closed {
+		t.Fatal("expected the connection to be closed")
+	}
+}
@@ -0,0 +1,284 @@
+package le_go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHTTPEndpoint is the URL of the Logentries HTTP ingestion
+	// endpoint, the token is appended to it
+	DefaultHTTPEndpoint = "https://webhook.logentries.com/noformat/logs/"
+
+	// the lines are posted once they reach httpBatchSize bytes
+	// or httpBatchDelay after the first of them
+	httpBatchSize  = 64 << 10
+	httpBatchDelay = time.Second
+
+	// the number of attempts of a post answered with a 5xx status and the
+	// backoff before the first retry, which doubles with every retry
+	httpAttempts = 3
+	httpBackoff  = 100 * time.Millisecond
+)
+
+var errHTTPClosed = errors.New("le_go: HTTP transport is closed")
+
+// httpStatusError is returned when the endpoint rejects a post
+type httpStatusError struct {
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("le_go: HTTP endpoint answered %d %s", e.status, http.StatusText(e.status))
+}
+
+// httpReadError is returned by httpConn.Read, the HTTP transport has
+// nothing to read so it reports a timeout, as an open TCP connection does
+type httpReadError struct{}
+
+func (httpReadError) Error() string   { return "le_go: HTTP transport has nothing to read" }
+func (httpReadError) Timeout() bool   { return true }
+func (httpReadError) Temporary() bool { return true }
+
+// httpConn is a net.Conn which posts the written lines to an HTTP
+// endpoint in newline-delimited batches. The batches are posted without
+// holding the logger lock, so the retries don't block the logging goroutines
+type httpConn struct {
+	endpoint string
+	client   *http.Client
+
+	// token returns the current token of the logger,
+	// which is appended to the endpoint
+	token func() string
+
+	// failed hands the lines of a batch which couldn't be posted to the
+	// logger, which stores them in the spool or the retry queue or writes
+	// them to the fallback writer, see Logger.writeFailed.
+	// errorf reports the batches which are dropped
+	failed func(lines []byte, err error) (int, error)
+	errorf func(format string, v ...interface{})
+
+	mu     sync.Mutex
+	buf    []byte
+	timer  *time.Timer
+	closed bool
+
+	// posting is the number of batches being posted,
+	// idle is signaled when it drops to 0
+	posting int
+	idle    *sync.Cond
+}
+
+// NewHTTP creates a new Logger instance which posts the lines to the
+// Logentries HTTP ingestion endpoint, e.g. DefaultHTTPEndpoint, instead of
+// writing them to a TCP connection. It suits networks where outgoing TCP
+// connections are restricted.
+// The token is sent in the URL so it isn't added to the lines.
+// The lines are posted in batches of up to 64KB, a second after the first
+// of them or when the logger is flushed, the posts answered with a 5xx
+// status are retried. The batches which still fail are handled as failed
+// writes, e.g. written to the fallback writer.
+func NewHTTP(endpoint, token string) *Logger {
+	logger := &Logger{
+		token:     token,
+		omitToken: true,
+		fixedConn: true,
+	}
+
+	c := &httpConn{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		token: func() string {
+			logger.mu.Lock()
+			defer logger.mu.Unlock()
+
+			return logger.token
+		},
+		failed: logger.writeFailed,
+		errorf: logger.errorf,
+	}
+	c.idle = sync.NewCond(&c.mu)
+
+	logger.conn = c
+
+	return logger
+}
+
+// Write adds b to the batch, the batch is posted in the background
+// if it is full
+func (c *httpConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, errHTTPClosed
+	}
+
+	if len(c.buf) == 0 {
+		c.timer = time.AfterFunc(httpBatchDelay, c.flushBackground)
+	}
+	c.buf = append(c.buf, b...)
+
+	if len(c.buf) >= httpBatchSize {
+		body := c.takeLocked()
+
+		go func() {
+			if err := c.send(body); err != nil {
+				c.errorf("dropped posted lines: %v", err)
+			}
+		}()
+	}
+
+	return len(b), nil
+}
+
+// Flush posts the batch and waits for the batches being posted,
+// it must be called without the logger lock
+func (c *httpConn) Flush() error {
+	c.mu.Lock()
+	body := c.takeLocked()
+	c.mu.Unlock()
+
+	err := c.send(body)
+	c.wait()
+
+	return err
+}
+
+// flushBackground posts the batch once the batch delay elapses
+func (c *httpConn) flushBackground() {
+	c.mu.Lock()
+	body := c.takeLocked()
+	c.mu.Unlock()
+
+	if err := c.send(body); err != nil {
+		c.errorf("dropped posted lines: %v", err)
+	}
+}
+
+// takeLocked returns the batch and empties it, the batch is counted as
+// being posted until send is called with it. the lock must be held
+func (c *httpConn) takeLocked() []byte {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+
+	body := c.buf
+	c.buf = nil
+	c.posting++
+
+	return body
+}
+
+// send posts body, which was returned by takeLocked, if the post fails
+// body is handed to failed
+func (c *httpConn) send(body []byte) error {
+	defer func() {
+		c.mu.Lock()
+		if c.posting--; c.posting == 0 {
+			c.idle.Broadcast()
+		}
+		c.mu.Unlock()
+	}()
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	err := c.post(body)
+	if err == nil {
+		return nil
+	}
+
+	_, err = c.failed(body, err)
+
+	return err
+}
+
+// wait blocks until no batch is being posted
+func (c *httpConn) wait() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.posting > 0 {
+		c.idle.Wait()
+	}
+}
+
+// post sends body to the endpoint, retrying while it answers a 5xx status.
+// It must be called without any lock held since it sleeps between retries
+func (c *httpConn) post(body []byte) error {
+	backoff := httpBackoff
+	url := c.endpoint + c.token()
+
+	var err error
+	for attempt := 0; attempt < httpAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var resp *http.Response
+		resp, err = c.client.Post(url, "text/plain", bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode < 300 {
+			return nil
+		}
+
+		err = &httpStatusError{status: resp.StatusCode}
+		if resp.StatusCode < 500 {
+			return err
+		}
+	}
+
+	return err
+}
+
+// Close posts the batch, the lines written afterwards are rejected.
+// It must be called without the logger lock
+func (c *httpConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	body := c.takeLocked()
+	c.mu.Unlock()
+
+	err := c.send(body)
+	c.wait()
+
+	return err
+}
+
+func (c *httpConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, io.EOF
+	}
+
+	return 0, httpReadError{}
+}
+
+func (c *httpConn) LocalAddr() net.Addr                { return nil }
+func (c *httpConn) RemoteAddr() net.Addr               { return nil }
+func (c *httpConn) SetDeadline(t time.Time) error      { return nil }
+func (c *httpConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *httpConn) SetWriteDeadline(t time.Time) error { return nil }
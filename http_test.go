@@ -0,0 +1,176 @@
+package le_go
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewHTTP(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+		paths  []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		bodies = append(bodies, string(body))
+		paths = append(paths, r.URL.Path)
+	}))
+	defer server.Close()
+
+	le := NewHTTP(server.URL+"/logs/", "myToken")
+	defer le.Close()
+
+	le.SetPrefix("myPrefix")
+	le.Print("1")
+	le.Print("2")
+	le.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(bodies) != 1 {
+		t.Fatalf("expected a single post, got %q", bodies)
+	}
+
+	if want := "myPrefix 1\nmyPrefix 2\n"; bodies[0] != want {
+		t.Fatalf("expected %q, got %q", want, bodies[0])
+	}
+
+	if paths[0] != "/logs/myToken" {
+		t.Fatalf("expected the token in the path, got %q", paths[0])
+	}
+}
+
+func TestHTTPRetriesServerErrors(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	le := NewHTTP(server.URL+"/", "myToken")
+	le.Print("test")
+
+	if err := le.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if attempts != 2 {
+		t.Fatalf("expected the post to be retried once, got %d attempts", attempts)
+	}
+}
+
+func TestHTTPUsesCurrentToken(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		paths = append(paths, r.URL.Path)
+	}))
+	defer server.Close()
+
+	le := NewHTTP(server.URL+"/", "myToken")
+	defer le.Close()
+
+	le.SetToken("otherToken")
+	le.Print("test")
+	le.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(paths) != 1 || paths[0] != "/otherToken" {
+		t.Fatalf("expected the new token in the path, got %q", paths)
+	}
+}
+
+func TestHTTPFailedBatchGoesToFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	var fallback bytes.Buffer
+
+	le := NewHTTP(server.URL+"/", "myToken")
+	le.SetFallback(&fallback)
+	le.Print("test")
+
+	if err := le.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if fallback.String() != " test\n" {
+		t.Fatalf("expected the failed batch in the fallback, got %q", fallback.String())
+	}
+}
+
+func TestHTTPPostsWithoutLoggerLock(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer server.Close()
+
+	le := NewHTTP(server.URL+"/", "myToken")
+	defer le.Close()
+
+	le.Print("1")
+
+	flushed := make(chan struct{})
+	go func() {
+		le.Flush()
+		close(flushed)
+	}()
+
+	<-entered
+
+	printed := make(chan struct{})
+	go func() {
+		le.Print("2")
+		close(printed)
+	}()
+
+	select {
+	case <-printed:
+	case <-time.After(time.Second):
+		t.Fatal("expected logging not to wait for the post")
+	}
+
+	close(release)
+	<-flushed
+}
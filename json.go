@@ -0,0 +1,113 @@
+package le_go
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// tag is a key=value pair added to every message, see SetTags()
+type tag struct {
+	key   string
+	value string
+}
+
+// jsonMessage returns s as a JSON object with the severity, timestamp,
+// prefix, process fields, file, line, stack, the fields of the message
+// and the tags as separate fields.
+// the logger lock must be held
+func (logger *Logger) jsonMessage(severity Severity, s, file string, line int, stack string, fields []tag) string {
+	b := []byte(`{"severity":`)
+	b = appendJSONString(b, logger.severityLabel(severity))
+
+	if logger.timestamped() {
+		b = append(b, `,"time":`...)
+		b = logger.appendJSONTimestamp(b)
+	}
+
+	if logger.prefix != "" {
+		b = append(b, `,"prefix":`...)
+		b = appendJSONString(b, logger.prefix)
+	}
+
+	if logger.processHost != "" {
+		b = append(b, `,"hostname":`...)
+		b = appendJSONString(b, logger.processHost)
+	}
+
+	if logger.processPID != 0 {
+		b = append(b, `,"pid":`...)
+		b = strconv.AppendInt(b, int64(logger.processPID), 10)
+	}
+
+	b = append(b, `,"message":`...)
+	b = appendJSONString(b, strings.TrimSuffix(s, lineSep))
+
+	if file != "" {
+		b = append(b, `,"file":`...)
+		b = appendJSONString(b, file)
+		b = append(b, `,"line":`...)
+		b = strconv.AppendInt(b, int64(line), 10)
+	}
+
+	if logger.goroutineID {
+		b = append(b, `,"goroutine":`...)
+		b = strconv.AppendUint(b, goroutineID(), 10)
+	}
+
+	if stack != "" {
+		b = append(b, `,"stack":`...)
+		b = appendJSONString(b, stack)
+	}
+
+	for _, t := range fields {
+		b = append(b, ',')
+		b = appendJSONString(b, t.key)
+		b = append(b, ':')
+		b = appendJSONString(b, t.value)
+	}
+
+	for _, t := range logger.tagFields {
+		b = append(b, ',')
+		b = appendJSONString(b, t.key)
+		b = append(b, ':')
+		b = appendJSONString(b, t.value)
+	}
+
+	return string(append(b, '}'))
+}
+
+// appendJSONTimestamp appends the header timestamp of the current time to
+// b as a JSON string, or as a number with the LEpochMillis flag.
+// the logger lock must be held
+func (logger *Logger) appendJSONTimestamp(b []byte) []byte {
+	ts := logger.appendTimestamp(nil, logger.now())
+	ts = ts[:len(ts)-1]
+
+	if logger.flag&LEpochMillis != 0 {
+		return append(b, ts...)
+	}
+
+	return appendJSONString(b, string(ts))
+}
+
+// appendJSONString appends s encoded as a JSON string to b
+func appendJSONString(b []byte, s string) []byte {
+	encoded, _ := json.Marshal(s)
+
+	return append(b, encoded...)
+}
+
+// SetJSONFormat enables writing the messages logged with Output and the
+// Print, Fatal and Panic methods as JSON objects, with the severity,
+// timestamp, prefix, file, line and tags as separate fields instead of
+// inline text. The timestamp is added as by the flags and the timestamp
+// format, it is a number with the LEpochMillis flag.
+// The access token is still written ahead of the object.
+// Messages longer than the maximum log length are still split.
+func (logger *Logger) SetJSONFormat(enabled bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.json = enabled
+}
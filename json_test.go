@@ -0,0 +1,110 @@
+package le_go
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestJSONFormatCallerFields(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.SetFlags(log.Lshortfile)
+	le.SetJSONFormat(true)
+	le.SetTags(map[string]string{"env": "prod"})
+
+	le.Println("test")
+
+	writes := conn.Written()
+	if len(writes) != 1 || !strings.HasPrefix(string(writes[0]), "myToken {") {
+		t.Fatalf("expected a JSON object after the token, got %q", writes)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(writes[0][len("myToken "):], &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	if fields["message"] != "test" || fields["severity"] != "INFO" ||
+		fields["prefix"] != "myPrefix" || fields["env"] != "prod" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	if fields["file"] != "json_test.go" {
+		t.Fatalf("expected the file field to be json_test.go, got %v", fields["file"])
+	}
+
+	if line, ok := fields["line"].(float64); !ok || line <= 0 {
+		t.Fatalf("expected a line field, got %v", fields["line"])
+	}
+}
+
+func TestShortfileInline(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetFlags(log.Lshortfile)
+	le.Print("test")
+
+	if writes := conn.Written(); len(writes) != 1 || !strings.HasPrefix(string(writes[0]), "myToken  json_test.go:") {
+		t.Fatalf("expected the file and line before the message, got %q", writes)
+	}
+}
+
+func TestJSONFormatProcessFields(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetJSONFormat(true)
+	le.SetProcessFields(true, true)
+
+	le.Print("test")
+
+	writes := conn.Written()
+	if len(writes) != 1 {
+		t.Fatalf("expected 1 write, got %q", writes)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(writes[0][len("myToken "):], &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	hostname, _ := os.Hostname()
+	if fields["hostname"] != hostname || fields["pid"] != float64(os.Getpid()) {
+		t.Fatalf("expected the hostname and pid fields, got %v", fields)
+	}
+}
+
+func TestJSONFormatFiltersBeforeEncoding(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetJSONFormat(true)
+	le.SetStripANSI(true)
+	le.SetRedaction([]*regexp.Regexp{regexp.MustCompile(`a"b`)}, `"x"`)
+
+	le.Print("\x1b[31mred\x1b[0m a\"b")
+
+	writes := conn.Written()
+	if len(writes) != 1 {
+		t.Fatalf("expected 1 write, got %q", writes)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(writes[0][len("myToken "):], &fields); err != nil {
+		t.Fatalf("expected valid JSON, got %q: %v", writes[0], err)
+	}
+
+	if fields["message"] != `red "x"` {
+		t.Fatalf("expected the message to be filtered, got %q", fields["message"])
+	}
+}
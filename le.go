@@ -5,13 +5,25 @@
 package le_go
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 // Logger represents a Logentries logger,
@@ -21,67 +33,694 @@ import (
 // log operations can be invoked in a non-blocking way by calling them from
 // a goroutine.
 type Logger struct {
+	// accessed atomically, kept first for 64-bit alignment
+	dropped    uint64
+	split      uint64
+	sent       uint64
+	errors     uint64
+	reconnects uint64
+	errFailed  uint64
+
+	// writeStarted is the time in Unix nanoseconds when the write in
+	// progress started, 0 if none is
+	writeStarted int64
+
 	conn   net.Conn
 	flag   int
 	mu     sync.Mutex
 	prefix string
 	token  string
-	buf    []byte
+
+	// tokenBytes caches the token followed by a space for the token tokenBytesOf
+	tokenBytes   []byte
+	tokenBytesOf string
+
+	// omitToken leaves the token out of the lines, for transports which
+	// send it separately
+	omitToken bool
+
+	// severityTokens holds the tokens, followed by a space, which replace
+	// the token for the messages logged with a severity
+	severityTokens map[Severity][]byte
+
+	fallback  io.Writer
+	tee       io.Writer
+	spool     *spool
+	spoolStop chan struct{}
+	queue     *retryQueue
+	ordered   *orderedWriter
+	batch     *batch
+
+	// messages of flushSeverity or more severe write the batch,
+	// if flushOnSeverity is set
+	flushSeverity   Severity
+	flushOnSeverity bool
+
+	async     *asyncWriter
+	dedup     *dedup
+	rateLimit *tokenBucket
+	sampler   *sampler
+	syslog    *syslogFormat
+	json      bool
+	closed    bool
+
+	// connectedAt is when the current connection was opened or set
+	connectedAt time.Time
+
+	// draining rejects the new messages while Drain writes the pending ones
+	draining bool
+
+	// errOutput receives the logger diagnostics, e.g. about dropped lines,
+	// nil means os.Stderr. errMu guards it and serializes the writes
+	errMu     sync.Mutex
+	errOutput io.Writer
+
+	// closing is closed once Close completes
+	closing chan struct{}
+
+	// the length and number of lines of the last split message
+	lastSplitLength int
+	lastSplitChunks int
+
+	// reconnecting is disabled for connections supplied by the caller
+	fixedConn bool
+	dial      DialFunc
+
+	validateToken bool
+
+	// breaker suppresses the connections after repeated failures
+	breaker *breaker
+
+	// onReconnect is called after a connection replaced a previous one and
+	// onError with the errors of the writes, without the logger lock held
+	onReconnect func()
+	onError     func(err error)
+
+	// retry decides the retries of the failed writes, nil means
+	// defaultRetryPolicy
+	retry RetryPolicy
+
+	// nop discards all the messages without connecting, it is set only
+	// when the logger is created
+	nop bool
+
+	// the line separator and its replacement inside messages,
+	// empty means lineSep and lineSepReplacement
+	sep         string
+	replacement string
+
+	// exitFunc is called by Fatal after beforeExit, nil means os.Exit
+	exitFunc   func(code int)
+	beforeExit func()
+
+	// rethrowPanics re-panics after reporting a panic while writing
+	rethrowPanics bool
+
+	// stackTraces adds a stack trace to the messages logged with
+	// SeverityError or a more severe severity
+	stackTraces bool
+
+	// stripANSI removes the ANSI escape codes from messages
+	stripANSI bool
+
+	// the matches of the redact patterns are replaced with redactWith
+	redact     []*regexp.Regexp
+	redactWith []byte
+
+	// tags are the key=value pairs added after every message,
+	// tagFields are the same tags sorted by key for the JSON format
+	tags      string
+	tagFields []tag
+
+	// goroutineID adds the ID of the logging goroutine to the header
+	goroutineID bool
+
+	// traceExtractor returns the trace and span IDs and contextExtractor
+	// the key-value pairs added to the messages logged with a context
+	traceExtractor   func(ctx context.Context) (traceID, spanID string)
+	contextExtractor func(ctx context.Context) []interface{}
+
+	// timestampFormat is the time layout of the header timestamp,
+	// empty means the format selected by the flags
+	timestampFormat string
+
+	// location is the time zone of the header timestamp,
+	// nil means the one selected by the LUTC flag
+	location *time.Location
+
+	// the position and casing of the severity in the header
+	severityPosition  SeverityPosition
+	severityLowercase bool
+
+	// severityLabels replaces the names of the severities, see Severity.String()
+	severityLabels map[Severity]string
+
+	// severityPrefixes detect the severity of the messages, longest first,
+	// detectSeverity is set if there is any
+	severityPrefixes []severityPrefix
+	detectSeverity   int32
+
+	// messages larger than maxMessageSize are truncated or dropped
+	maxMessageSize int
+	oversizePolicy OversizePolicy
+
+	// processFields holds the hostname and PID fields added to the header,
+	// processHost and processPID are their values, empty and 0 if disabled
+	processFields string
+	processHost   string
+	processPID    int
+
+	// preserveNewlines disables replacing the line breaks inside messages
+	preserveNewlines bool
+
+	// normalizeCR converts \r\n and lone \r line breaks to \n
+	normalizeCR bool
+
+	// hosts are tried in order starting from the last one which
+	// was dialed successfully
+	hosts     []string
+	hostIndex int
+	tlsConfig *tls.Config
+
+	// clientCerts are presented to logentries.com for mutual TLS
+	clientCerts []tls.Certificate
+
+	// serverName is the name verified in the certificate of the hosts,
+	// empty means the host name
+	serverName string
+
+	tcpKeepAlive time.Duration
+
+	// tcpDelay enables Nagle's algorithm, which Go disables by default
+	tcpDelay bool
+
+	// compress wraps the dialed connections in a gzip stream
+	compress bool
+
+	// handshakeTimeout bounds the TLS handshake, 0 means the default
+	handshakeTimeout time.Duration
+
+	// lookupHost and dialContext resolve the hosts and dial their
+	// addresses, nil means the net package defaults
+	lookupHost  func(ctx context.Context, host string) ([]string, error)
+	dialContext func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// clock tells the time of the timestamps and waits between retries,
+	// nil means the time package
+	clock clock
+
+	// dnsCache holds the addresses of the hosts for dnsCacheTTL,
+	// they are resolved on every dial if it is 0
+	dnsCache    map[string]dnsEntry
+	dnsCacheTTL time.Duration
+
+	keepAlive *keepAlive
+}
+
+// keepAlive controls the background goroutine checking the connection
+type keepAlive struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// DialFunc opens a connection for the logger to write to
+type DialFunc func() (net.Conn, error)
+
+// Stats holds counters describing the logger state
+type Stats struct {
+	// Dropped is the number of lines discarded by the logger
+	Dropped uint64
+	// QueueDepth is the number of lines waiting in the retry queue
+	QueueDepth int
+	// Sent is the number of messages written to the connection, the lines
+	// stored by a failed write count once each when they are replayed from
+	// the spool or the retry queue
+	Sent uint64
+	// Errors is the number of failed writes, including the ones whose lines
+	// were then stored in the spool or the retry queue or written to the
+	// fallback writer
+	Errors uint64
+	// Reconnects is the number of connections opened to replace a previous one
+	Reconnects uint64
+	// BreakerOpen is set while the circuit breaker suppresses the connections
+	BreakerOpen bool
+	// ErrOutputFailures is the number of diagnostics which the error output
+	// failed to write, they are written to os.Stderr instead
+	ErrOutputFailures uint64
+	// Split is the number of messages longer than the maximum log length,
+	// which were split into multiple lines
+	Split uint64
+	// LastSplitLength is the length in bytes of the last split message
+	// and LastSplitChunks is the number of lines it was split into
+	LastSplitLength int
+	LastSplitChunks int
+}
+
+const (
+	lineSep = "\n"
+
+	// the default replacement of the line breaks inside messages
+	lineSepReplacement = "\u2028"
+
+	// the maximum length of a single line, longer messages are split
+	maxLogLength = 65000
+
+	// write buffers which grew larger are not pooled,
+	// so a large message doesn't keep its buffer alive
+	maxPooledBufSize = 64 << 10
+
+	// how long Close waits for the messages pending in ordered mode
+	closeTimeout = 5 * time.Second
+
+	// the default Logentries data endpoint
+	defaultHost = "data.logentries.com:443"
+)
+
+var (
+	// ErrClosed is returned when writing to a closed or draining logger
+	ErrClosed = errors.New("le_go: logger is closed")
+
+	// ErrNotConnected is matched by the errors returned when the connection
+	// to logentries.com can't be opened, with errors.Is, they hold the
+	// error of the dial
+	ErrNotConnected = errors.New("le_go: not connected")
+
+	errFixedConn = errors.New("le_go: can't reopen a connection supplied by the caller")
+
+	// bufPool holds the buffers the log lines are built in
+	bufPool = sync.Pool{
+		New: func() interface{} {
+			buf := make([]byte, 0, 1024)
+			return &buf
+		},
+	}
+
+	// ansiPattern matches ANSI CSI escape sequences such as colors
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]`)
+
+	tokenPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// notConnectedError is returned when a dial fails, it matches
+// ErrNotConnected with errors.Is
+type notConnectedError struct {
+	err error
+}
+
+func (e *notConnectedError) Error() string {
+	return "le_go: not connected: " + e.err.Error()
 }
 
-const lineSep = "\n"
+// Is reports if target is ErrNotConnected
+func (e *notConnectedError) Is(target error) bool {
+	return target == ErrNotConnected
+}
+
+// Unwrap returns the error of the dial
+func (e *notConnectedError) Unwrap() error {
+	return e.err
+}
 
 // Connect creates a new Logger instance and opens a TCP connection to
 // logentries.com,
 // The token can be generated at logentries.com by adding a new log,
 // choosing manual configuration and token based TCP connection.
-func Connect(token string) (*Logger, error) {
-	logger := Logger{
+func Connect(token string, options ...Option) (*Logger, error) {
+	logger := ConnectLazy(token, options...)
+
+	if err := logger.openConnection(); err != nil {
+		return nil, err
+	}
+
+	return logger, nil
+}
+
+// ConnectLazy creates a new Logger instance without opening a connection,
+// the TCP connection to logentries.com is opened by the first write.
+// It allows starting an application while logentries.com is unreachable.
+func ConnectLazy(token string, options ...Option) *Logger {
+	logger := &Logger{
+		hosts: []string{defaultHost},
 		token: token,
 	}
 
+	for _, option := range options {
+		option(logger)
+	}
+
+	return logger
+}
+
+// Option configures a Logger before it connects
+type Option func(*Logger)
+
+// WithTokenValidation validates the token with ValidateToken before
+// every dial, so Connect fails on a malformed token without dialing
+func WithTokenValidation() Option {
+	return func(logger *Logger) {
+		logger.validateToken = true
+	}
+}
+
+// WithUnixSocket makes the logger write to the unix domain socket at path,
+// e.g. of a local log relay, instead of dialing logentries.com.
+// The socket is dialed again to reconnect.
+func WithUnixSocket(path string) Option {
+	return func(logger *Logger) {
+		logger.dial = func() (net.Conn, error) {
+			return net.Dial("unix", path)
+		}
+	}
+}
+
+// ConnectHosts is same as Connect() but connects to the first reachable
+// host:port of hosts, the other hosts are used for failover
+func ConnectHosts(token string, hosts ...string) (*Logger, error) {
+	logger := ConnectLazy(token)
+	logger.hosts = hosts
+
 	if err := logger.openConnection(); err != nil {
 		return nil, err
 	}
 
-	return &logger, nil
+	return logger, nil
+}
+
+// NewWithConn creates a new Logger instance which writes to conn instead of
+// opening a TCP connection to logentries.com,
+// it allows using custom transports and testing against net.Pipe.
+// Unless a DialFunc is set the logger never reconnects,
+// once conn is closed writing returns an error.
+func NewWithConn(conn net.Conn, token string) *Logger {
+	return &Logger{
+		conn:        conn,
+		token:       token,
+		fixedConn:   true,
+		connectedAt: time.Now(),
+	}
+}
+
+// NewNop creates a Logger which discards all the messages without ever
+// connecting, for tests and environments where logging is disabled.
+// Its Write methods report the whole input as written, Fatal still exits
+// and Panic still panics.
+func NewNop() *Logger {
+	return &Logger{nop: true, fixedConn: true}
+}
+
+// StdLogger returns a standard library logger writing through l, for
+// libraries which accept a *log.Logger. Its lines are written with Write.
+// The prefix, flags and file:line of the returned logger are handled by the
+// log package, which resolves the caller of its own Print methods,
+// so they don't depend on a calldepth passed to l.
+func StdLogger(l *Logger, prefix string, flag int) *log.Logger {
+	return log.New(l, prefix, flag)
+}
+
+// ValidateToken returns an error if token isn't shaped like a Logentries
+// access token, which is a UUID such as 2bfbea1e-10c3-4419-bdad-7e6435882e1f
+func ValidateToken(token string) error {
+	if token == "" {
+		return errors.New("le_go: empty token")
+	}
+
+	if !tokenPattern.MatchString(token) {
+		return errors.New("le_go: token is not UUID-shaped")
+	}
+
+	return nil
 }
 
-// Close closes the TCP connection to logentries.com
+// Close writes the messages pending in ordered mode, in batching mode, in
+// the retry queue and the summary of repeated messages and closes the TCP connection to logentries.com,
+// it waits up to closeTimeout for the messages pending in ordered mode
+// and for the ones written from goroutines.
+// It is safe to call Close multiple times, also concurrently.
+// Once closed, writing to the logger returns an error.
 func (logger *Logger) Close() error {
-	if logger.conn != nil {
-		return logger.conn.Close()
+	return logger.close(closeTimeout)
+}
+
+// close is same as Close() but waits up to timeout for the pending messages
+func (logger *Logger) close(timeout time.Duration) error {
+	logger.flushDedup(-1)
+
+	logger.mu.Lock()
+	if logger.closed {
+		logger.mu.Unlock()
+		return nil
+	}
+
+	if logger.closing != nil {
+		closing := logger.closing
+		logger.mu.Unlock()
+
+		<-closing
+		return nil
+	}
+
+	logger.closing = make(chan struct{})
+	defer close(logger.closing)
+
+	o, a := logger.ordered, logger.async
+	logger.ordered = nil
+	logger.mu.Unlock()
+
+	if o != nil {
+		o.shutdown()
+		o.waitTimeout(timeout)
+		close(o.stop)
+	}
+
+	if a != nil {
+		a.waitTimeout(timeout)
+	}
+
+	// the batched messages are written while the queue can still take
+	// the failed lines
+	logger.mu.Lock()
+	logger.flushBatchUnlock(time.Time{})
+
+	logger.mu.Lock()
+	q := logger.queue
+	logger.queue = nil
+	logger.mu.Unlock()
+
+	if q != nil {
+		close(q.stop)
+		<-q.done
+	}
+
+	logger.mu.Lock()
+
+	if q != nil {
+		logger.flushRetryQueue(q)
+	}
+
+	logger.closed = true
+
+	if logger.spoolStop != nil {
+		close(logger.spoolStop)
+		logger.spoolStop = nil
+	}
+
+	if logger.keepAlive != nil {
+		close(logger.keepAlive.stop)
+		logger.keepAlive = nil
+	}
+
+	conn := logger.conn
+	logger.mu.Unlock()
+
+	// the messages are rejected from now on, the connection is closed
+	// unlocked since the HTTP transport posts its last lines on close
+	if conn != nil {
+		return conn.Close()
 	}
 
 	return nil
 }
 
+// flushRetryQueue tries to write the lines left in a stopped queue once,
+// the lines which fail are dropped
+func (logger *Logger) flushRetryQueue(q *retryQueue) {
+	if q.held != nil {
+		if _, err := logger.writeConn(q.held, time.Time{}); err != nil {
+			atomic.AddUint64(&logger.dropped, 1)
+			logger.errorf("dropped a queued line on close: %v", err)
+		} else {
+			atomic.AddUint64(&logger.sent, 1)
+		}
+	}
+
+	for {
+		select {
+		case line := <-q.lines:
+			if _, err := logger.writeConn(line, time.Time{}); err != nil {
+				atomic.AddUint64(&logger.dropped, 1)
+				logger.errorf("dropped a queued line on close: %v", err)
+			} else {
+				atomic.AddUint64(&logger.sent, 1)
+			}
+		default:
+			return
+		}
+	}
+}
+
 // Opens a TCP connection to logentries.com
 func (logger *Logger) openConnection() error {
-	conn, err := tls.Dial("tcp", "data.logentries.com:443", &tls.Config{})
+	if logger.validateToken {
+		if err := ValidateToken(logger.token); err != nil {
+			return err
+		}
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if logger.fixedConn && logger.dial == nil {
+		return errFixedConn
+	}
+
+	if logger.breaker != nil && !logger.breaker.allow(logger.now()) {
+		return errCircuitOpen
+	}
+
+	// the old connection is replaced, close it so it doesn't leak
+	reconnect := logger.conn != nil
+	if reconnect {
+		logger.conn.Close()
+	}
+
+	if logger.dial != nil {
+		conn, err = logger.dial()
+	} else {
+		conn, err = logger.dialTLS()
+	}
+
+	if logger.breaker != nil {
+		logger.breaker.record(logger.now(), err)
+	}
+
 	if err != nil {
-		return err
+		return &notConnectedError{err: err}
 	}
+
+	if logger.compress {
+		conn = newGzipConn(conn)
+	}
+
+	if reconnect {
+		atomic.AddUint64(&logger.reconnects, 1)
+
+		// the lock is held, the callback runs apart so it may log
+		if logger.onReconnect != nil {
+			go logger.onReconnect()
+		}
+	}
+
 	logger.conn = conn
+	logger.connectedAt = logger.now()
 	return nil
 }
 
+// dialTLS opens a TLS connection to the first reachable logger host,
+// starting from the last host which was dialed successfully
+func (logger *Logger) dialTLS() (net.Conn, error) {
+	hosts := logger.hosts
+	if len(hosts) == 0 {
+		hosts = []string{defaultHost}
+	}
+
+	config := logger.tlsConfig
+	if config == nil {
+		config = &tls.Config{}
+	}
+
+	if len(logger.clientCerts) > 0 || logger.serverName != "" {
+		config = config.Clone()
+	}
+
+	if len(logger.clientCerts) > 0 {
+		config.Certificates = logger.clientCerts
+	}
+
+	if logger.serverName != "" {
+		config.ServerName = logger.serverName
+	}
+
+	dialer := &net.Dialer{
+		KeepAlive: logger.tcpKeepAlive,
+	}
+
+	var err error
+	for i := range hosts {
+		index := (logger.hostIndex + i) % len(hosts)
+
+		var conn net.Conn
+		if conn, err = logger.dialHost(dialer, hosts[index], config); err == nil {
+			logger.hostIndex = index
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
+
+// maxPeekedBytes bounds the server data read by isOpenConnection which is
+// kept for the next read of the connection, the extra bytes are discarded
+const maxPeekedBytes = 4096
+
 // It returns if the TCP connection to logentries.com is open
 func (logger *Logger) isOpenConnection() bool {
 	if logger.conn == nil {
 		return false
 	}
 
-	buf := make([]byte, 1)
+	// the connection itself is probed even if a previous probe kept
+	// bytes, the server may have closed it since
+	conn := logger.conn
+	peeked, _ := conn.(*peekedConn)
+	if peeked != nil {
+		conn = peeked.Conn
+	}
+
+	buf := make([]byte, 512)
+
+	conn.SetReadDeadline(time.Now())
+
+	// the data the server sent is read until the probe times out or fails,
+	// at most maxPeekedBytes per probe
+	var err error
+	for read := 0; err == nil && read < maxPeekedBytes; {
+		var n int
+		n, err = conn.Read(buf)
+		read += n
+
+		// the server sent data, it is kept for the next read of the connection
+		if n > 0 {
+			if peeked == nil {
+				peeked = &peekedConn{Conn: conn}
+				logger.conn = peeked
+			}
+
+			peeked.keep(buf[:n])
+		}
+	}
 
-	logger.conn.SetReadDeadline(time.Now())
+	if err == nil {
+		conn.SetReadDeadline(time.Time{})
 
-	_, err := logger.conn.Read(buf)
+		return true
+	}
 
 	switch err.(type) {
 	case net.Error:
 		if err.(net.Error).Timeout() == true {
-			logger.conn.SetReadDeadline(time.Time{})
+			conn.SetReadDeadline(time.Time{})
 
 			return true
 		}
@@ -90,9 +729,49 @@ func (logger *Logger) isOpenConnection() bool {
 	return false
 }
 
+// peekedConn returns the bytes read by isOpenConnection before
+// reading from the connection, so that probing it loses no data
+type peekedConn struct {
+	net.Conn
+	peeked []byte
+}
+
+// keep appends p to the peeked bytes, up to maxPeekedBytes
+func (c *peekedConn) keep(p []byte) {
+	if room := maxPeekedBytes - len(c.peeked); len(p) > room {
+		p = p[:room]
+	}
+
+	c.peeked = append(c.peeked, p...)
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	if len(c.peeked) == 0 {
+		return c.Conn.Read(p)
+	}
+
+	n := copy(p, c.peeked)
+	c.peeked = c.peeked[n:]
+
+	return n, nil
+}
+
+// Flush flushes the wrapped connection, if it buffers the writes
+func (c *peekedConn) Flush() error {
+	if f, ok := c.Conn.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
 // It ensures that the TCP connection to logentries.com is open.
 // If the connection is closed, a new one is opened.
 func (logger *Logger) ensureOpenConnection() error {
+	if logger.closed {
+		return ErrClosed
+	}
+
 	if !logger.isOpenConnection() {
 		if err := logger.openConnection(); err != nil {
 			return err
@@ -102,22 +781,47 @@ func (logger *Logger) ensureOpenConnection() error {
 	return nil
 }
 
-// Fatal is same as Print() but calls to os.Exit(1)
+// Fatal is same as Print() but logs with SeverityCritical and calls to
+// os.Exit(1), see SetExitFunc().
+// The message is written before exiting even when concurrent writes,
+// sampling or rate limiting are enabled, as for Panic()
 func (logger *Logger) Fatal(v ...interface{}) {
-	logger.Output(2, fmt.Sprint(v...))
-	os.Exit(1)
+	logger.outputTerminal(2, SeverityCritical, fmt.Sprint(v...))
+	logger.exit()
 }
 
-// Fatalf is same as Printf() but calls to os.Exit(1)
+// Fatalf is same as Printf() but logs with SeverityCritical and calls to
+// os.Exit(1), see SetExitFunc()
 func (logger *Logger) Fatalf(format string, v ...interface{}) {
-	logger.Output(2, fmt.Sprintf(format, v...))
-	os.Exit(1)
+	logger.outputTerminal(2, SeverityCritical, fmt.Sprintf(format, v...))
+	logger.exit()
 }
 
-// Fatalln is same as Println() but calls to os.Exit(1)
+// Fatalln is same as Println() but logs with SeverityCritical and calls to
+// os.Exit(1), see SetExitFunc()
 func (logger *Logger) Fatalln(v ...interface{}) {
-	logger.Output(2, fmt.Sprintln(v...))
-	os.Exit(1)
+	logger.outputTerminal(2, SeverityCritical, fmt.Sprintln(v...))
+	logger.exit()
+}
+
+// exit writes the pending messages, waiting up to closeTimeout,
+// runs the before exit hook and exits with code 1
+func (logger *Logger) exit() {
+	logger.FlushTimeout(closeTimeout)
+
+	logger.mu.Lock()
+	exit, beforeExit := logger.exitFunc, logger.beforeExit
+	logger.mu.Unlock()
+
+	if beforeExit != nil {
+		beforeExit()
+	}
+
+	if exit == nil {
+		exit = os.Exit
+	}
+
+	exit(1)
 }
 
 // Flags returns the logger flags
@@ -125,48 +829,390 @@ func (logger *Logger) Flags() int {
 	return logger.flag
 }
 
-// Output does the actual writing to the TCP connection
-func (logger *Logger) Output(calldepth int, s string) error {
-	var (
-		err        error
-		waitPeriod = time.Millisecond
-	)
-	for {
-		_, err = logger.Write([]byte(s))
-		if err != nil {
-			if connectionErr := logger.openConnection(); connectionErr != nil {
-				return connectionErr
-			}
-			waitPeriod *= 2
-			time.Sleep(waitPeriod)
-			continue
-		}
-		return err
+// Flush waits until all the messages submitted in ordered mode or written
+// from goroutines are written and writes the messages batched in batching mode
+// and the summary of repeated messages, see SetDedup()
+func (logger *Logger) Flush() {
+	if err := logger.Sync(); err != nil {
+		logger.errorf("dropped batched messages: %v", err)
 	}
 }
 
-// Panic is same as Print() but calls to panic
-func (logger *Logger) Panic(v ...interface{}) {
-	s := fmt.Sprint(v...)
-	logger.Output(2, s)
-	panic(s)
-}
+// FlushTimeout is same as Flush() but gives up after d,
+// it returns a *FlushTimeoutError if messages are still pending
+func (logger *Logger) FlushTimeout(d time.Duration) error {
+	deadline := time.Now().Add(d)
+	logger.flushDedup(-1)
 
-// Panicf is same as Printf() but calls to panic
-func (logger *Logger) Panicf(format string, v ...interface{}) {
-	s := fmt.Sprintf(format, v...)
-	logger.Output(2, s)
-	panic(s)
-}
+	logger.mu.Lock()
+	o, a := logger.ordered, logger.async
+	logger.mu.Unlock()
 
-// Panicln is same as Println() but calls to panic
-func (logger *Logger) Panicln(v ...interface{}) {
-	s := fmt.Sprintln(v...)
-	logger.Output(2, s)
-	panic(s)
-}
+	if o != nil {
+		if pending := o.waitTimeout(d); pending > 0 {
+			return &FlushTimeoutError{Pending: pending}
+		}
+	}
 
-// Prefix returns the logger prefix
+	if a != nil {
+		if pending := a.waitTimeout(time.Until(deadline)); pending > 0 {
+			return &FlushTimeoutError{Pending: pending}
+		}
+	}
+
+	logger.mu.Lock()
+	logger.flushBatchUnlock(deadline)
+
+	return nil
+}
+
+// IsConnected returns if the connection to logentries.com is open,
+// it doesn't write to the connection
+func (logger *Logger) IsConnected() bool {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	return !logger.closed && logger.isOpenConnection()
+}
+
+// Output does the actual writing to the TCP connection,
+// in ordered mode the message is queued and written in the background,
+// see also SetConcurrentWrites().
+// The message is logged with SeverityInfo, or the severity detected from
+// its beginning, see SetSeverityPrefixes()
+func (logger *Logger) Output(calldepth int, s string) error {
+	return logger.OutputSeverity(calldepth+1, logger.severityOf(s), s)
+}
+
+// OutputSeverity is same as Output() but logs the message with severity,
+// which is used by formats such as SetSyslogFormat().
+// calldepth is the number of frames to skip when looking up the file and
+// line for the Lshortfile and Llongfile flags, 1 is the caller of OutputSeverity
+func (logger *Logger) OutputSeverity(calldepth int, severity Severity, s string) error {
+	return logger.outputSeverity(nil, calldepth+1, severity, s, false)
+}
+
+// outputTerminal is same as OutputSeverity() but writes s synchronously
+// for the Fatal and Panic methods, so it is written before the process
+// exits or panics. It isn't dropped by the concurrent writes limit,
+// sampling or rate limiting
+func (logger *Logger) outputTerminal(calldepth int, severity Severity, s string) error {
+	return logger.outputSeverity(nil, calldepth+1, severity, s, true)
+}
+
+// outputSeverity is same as OutputSeverity(), s is written synchronously
+// and can't be dropped if terminal is set, see outputTerminal().
+// ctx is nil unless s is logged by a context-aware method, which gives up
+// when ctx is done and adds the fields of ctx, see OutputContext()
+func (logger *Logger) outputSeverity(ctx context.Context, calldepth int, severity Severity, s string, terminal bool) error {
+	contextAware := ctx != nil
+	if !contextAware {
+		ctx = context.Background()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if logger.nop {
+		return nil
+	}
+
+	file, line := logger.caller(calldepth)
+
+	if err := logger.lockContext(ctx); err != nil {
+		return err
+	}
+
+	if logger.drainingLocked() {
+		logger.mu.Unlock()
+		return ErrClosed
+	}
+
+	o, a, closed := logger.ordered, logger.async, logger.closed
+	if terminal {
+		a = nil
+	}
+
+	if !terminal && logger.sampler != nil && !logger.sampler.sampled(severity) {
+		logger.mu.Unlock()
+		return nil
+	}
+
+	// the summary of the repeats of the previous message is written first
+	var (
+		summaryHeader, summary string
+		last                   Severity
+	)
+	if d := logger.dedup; d != nil {
+		last = d.severity
+
+		var suppressed bool
+		if summary, suppressed = d.check(severity, s, logger.flushDedup); suppressed {
+			logger.mu.Unlock()
+			return nil
+		}
+
+		if summary != "" {
+			var err error
+			if summaryHeader, summary, err = logger.entry(last, summary, "", 0, "", nil); err != nil {
+				summary = ""
+			}
+		}
+	}
+
+	if !terminal && logger.rateLimit != nil && !logger.rateLimit.allow(logger.now()) {
+		logger.mu.Unlock()
+		atomic.AddUint64(&logger.dropped, 1)
+
+		if summary != "" {
+			logger.writeDedupSummary(o, a, closed, last, summaryHeader, summary)
+		}
+
+		return errRateLimited
+	}
+
+	var stack string
+	if logger.stackTraces && severity <= SeverityError {
+		stack = stackTrace(calldepth)
+	}
+
+	var fields []tag
+	if contextAware {
+		fields = logger.contextFields(ctx)
+	}
+
+	header, s, err := logger.entry(severity, s, file, line, stack, fields)
+	logger.mu.Unlock()
+
+	if summary != "" {
+		logger.writeDedupSummary(o, a, closed, last, summaryHeader, summary)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = logger.dispatch(ctx, o, a, closed, severity, header, s)
+
+	// in ordered mode the message is written after the pending ones
+	if terminal && o != nil {
+		o.waitTimeout(closeTimeout)
+	}
+
+	return err
+}
+
+// dispatch writes the message s logged with severity with header,
+// in ordered mode, from a goroutine or directly, given the ordered and
+// async writers and whether the logger was closed when s was logged.
+// ctx bounds the wait for room in the ordered queue and the direct write
+func (logger *Logger) dispatch(ctx context.Context, o *orderedWriter, a *asyncWriter, closed bool, severity Severity, header, s string) error {
+	if o != nil {
+		if err := o.push(ctx, severity, header, s); err != errOrderedStopped {
+			return err
+		}
+	}
+
+	if a != nil && !closed {
+		if async, err := logger.outputAsync(a, severity, header, s); async {
+			return err
+		}
+	}
+
+	return logger.output(ctx, severity, header, s)
+}
+
+// caller returns the file and line of the caller calldepth frames up,
+// relative to the function calling caller, if the Lshortfile or Llongfile
+// flags are set.
+// The exported methods take or pass the calldepth of their own caller:
+// 1 is the caller of Output, OutputSeverity or OutputContext, the Print,
+// Panic and Fatal methods pass 2 to skip themselves and the methods
+// wrapping Output, e.g. Pool.Output, pass calldepth+1
+func (logger *Logger) caller(calldepth int) (string, int) {
+	flag := logger.Flags()
+	if flag&(log.Lshortfile|log.Llongfile) == 0 {
+		return "", 0
+	}
+
+	_, file, line, ok := runtime.Caller(calldepth + 1)
+	if !ok {
+		return "???", 0
+	}
+
+	if flag&log.Lshortfile != 0 {
+		file = filepath.Base(file)
+	}
+
+	return file, line
+}
+
+// entry returns the header and the message written for s logged with
+// severity from file and line, the file and line are empty if unknown.
+// fields are added to the message as key=value pairs and stack is added
+// after the message unless it is empty. The maximum message size is
+// applied to s before the message is built, errMessageTooLarge is
+// returned if it is dropped.
+// the logger lock must be held
+func (logger *Logger) entry(severity Severity, s, file string, line int, stack string, fields []tag) (string, string, error) {
+	var location string
+	if file != "" && !logger.json {
+		location = file + ":" + strconv.Itoa(line) + ": "
+	}
+
+	s, err := logger.limitSizeString(logger.filterString(s), len(location))
+	if err != nil {
+		return "", "", err
+	}
+
+	stack = logger.filterString(stack)
+	fields = logger.filterFields(fields)
+
+	if logger.json {
+		return "", logger.jsonMessage(severity, s, file, line, stack, fields), nil
+	}
+
+	s = location + s
+
+	if len(fields) > 0 {
+		s = strings.TrimSuffix(s, lineSep) + formatTags(fields)
+	}
+
+	if stack != "" {
+		s = strings.TrimSuffix(s, lineSep) + lineSep + stack
+	}
+
+	return logger.header(severity), s, nil
+}
+
+// stackTrace returns the stack of the caller skip frames up, relative to
+// the function calling stackTrace, a function and its file:line per line
+func stackTrace(skip int) string {
+	pcs := make([]uintptr, 32)
+	pcs = pcs[:runtime.Callers(skip+2, pcs)]
+
+	var b strings.Builder
+
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+
+		b.WriteString(frame.Function + lineSep)
+		b.WriteString("\t" + frame.File + ":" + strconv.Itoa(frame.Line) + lineSep)
+
+		if !more {
+			break
+		}
+	}
+
+	return b.String()
+}
+
+// output writes s logged with severity with the header of its lines,
+// reconnecting and retrying as the retry policy allows while the write fails,
+// until the deadline of ctx. header was built when s was logged, so the
+// retries keep its timestamp
+func (logger *Logger) output(ctx context.Context, severity Severity, header, s string) error {
+	deadline, _ := ctx.Deadline()
+
+	logger.mu.Lock()
+	_, err := logger.writeStringUnlock(severity, header, s, deadline)
+
+	return err
+}
+
+// Pending returns the number of messages waiting to be written in ordered
+// mode and being written from goroutines, see SetOrdered() and
+// SetConcurrentWrites(). It helps to diagnose a Flush which takes too long,
+// with WriteStartedAt().
+func (logger *Logger) Pending() int {
+	logger.mu.Lock()
+	o, a := logger.ordered, logger.async
+	logger.mu.Unlock()
+
+	pending := 0
+
+	if o != nil {
+		o.mu.Lock()
+		pending += o.pending
+		o.mu.Unlock()
+	}
+
+	if a != nil {
+		a.mu.Lock()
+		pending += a.inFlight
+		a.mu.Unlock()
+	}
+
+	return pending
+}
+
+// Panic is same as Print() but logs with SeverityCritical and calls to panic
+func (logger *Logger) Panic(v ...interface{}) {
+	s := fmt.Sprint(v...)
+	logger.outputTerminal(2, SeverityCritical, s)
+	panic(s)
+}
+
+// Panicf is same as Printf() but logs with SeverityCritical and calls to panic
+func (logger *Logger) Panicf(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	logger.outputTerminal(2, SeverityCritical, s)
+	panic(s)
+}
+
+// Panicln is same as Println() but logs with SeverityCritical and calls to panic
+func (logger *Logger) Panicln(v ...interface{}) {
+	s := fmt.Sprintln(v...)
+	logger.outputTerminal(2, SeverityCritical, s)
+	panic(s)
+}
+
+// Ping writes an empty token prefixed line to verify the connection, it
+// isn't retried, a failed ping makes the next write reconnect
+func (logger *Logger) Ping() error {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.closed {
+		return ErrClosed
+	}
+
+	_, err := logger.writeConn([]byte(logger.token+" "+logger.lineSep()), time.Time{})
+
+	return err
+}
+
+// ReconnectNow closes the connection and opens a new one right away, e.g.
+// after a network change which left the connection unusable without it
+// being noticed. It waits for the write in progress, if any.
+func (logger *Logger) ReconnectNow() error {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.closed {
+		return ErrClosed
+	}
+
+	return logger.openConnection()
+}
+
+// ConnectionAge returns how long ago the current connection was opened
+// or set by NewWithConn or SetConn, it is 0 before the logger has a
+// connection, e.g. before the first write of a lazily connected logger
+func (logger *Logger) ConnectionAge() time.Duration {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.conn == nil || logger.connectedAt.IsZero() {
+		return 0
+	}
+
+	return logger.now().Sub(logger.connectedAt)
+}
+
+// Prefix returns the logger prefix
 func (logger *Logger) Prefix() string {
 	return logger.prefix
 }
@@ -186,43 +1232,1411 @@ func (logger *Logger) Println(v ...interface{}) error {
 	return logger.Output(2, fmt.Sprintln(v...))
 }
 
-// SetFlags sets the logger flags
+// SetErrOutput sets the writer receiving the logger diagnostics,
+// such as lines which were dropped in the background.
+// A nil writer restores the default, which is os.Stderr.
+// The diagnostics which w fails to write are written to os.Stderr,
+// Stats reports their number.
+func (logger *Logger) SetErrOutput(w io.Writer) {
+	logger.errMu.Lock()
+	defer logger.errMu.Unlock()
+
+	logger.errOutput = w
+}
+
+// SetExitFunc sets the function Fatal calls to exit and a hook which runs
+// before it, e.g. to close other resources.
+// The pending messages are written before the hook runs.
+// A nil exit function restores os.Exit.
+func (logger *Logger) SetExitFunc(exit func(code int), beforeExit func()) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.exitFunc = exit
+	logger.beforeExit = beforeExit
+}
+
+// SetFallback sets a writer which receives the formatted log lines
+// when they can't be written to logentries.com, a nil writer disables it
+func (logger *Logger) SetFallback(w io.Writer) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.fallback = w
+}
+
+// SetConn replaces the connection the logger writes to, the next write
+// uses conn. The old connection is closed if closeOld is set.
+// As with NewWithConn(), the logger doesn't reconnect once conn is closed
+// unless a DialFunc is set.
+func (logger *Logger) SetConn(conn net.Conn, closeOld bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if closeOld && logger.conn != nil && logger.conn != conn {
+		logger.conn.Close()
+	}
+
+	logger.conn = conn
+	logger.connectedAt = logger.now()
+	logger.fixedConn = true
+}
+
+// SetDialFunc sets the function used to open the logger connection,
+// it is used instead of dialing logentries.com over TLS, including on
+// reconnects. A nil DialFunc restores the default.
+func (logger *Logger) SetDialFunc(dial DialFunc) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.dial = dial
+}
+
+// SetFlags sets the logger flags.
+// The Lshortfile and Llongfile flags look up the caller of every message
+// with runtime.Caller, which roughly doubles the cost of logging a short
+// message. High-throughput services can leave them unset and keep the
+// timestamp flags, the caller isn't looked up at all then.
 func (logger *Logger) SetFlags(flag int) {
 	logger.flag = flag
 }
 
+// SetOrdered enables or disables ordered mode, in which messages are
+// written by a single goroutine in the order they were submitted.
+// Output doesn't wait for the message to be written in ordered mode,
+// Flush waits for the pending messages.
+// Ordered mode can't be enabled once the logger is closed.
+func (logger *Logger) SetOrdered(ordered bool) {
+	logger.mu.Lock()
+	o := logger.ordered
+
+	if ordered {
+		if o == nil && !logger.closed {
+			logger.ordered = newOrderedWriter()
+			go logger.runOrderedWriter(logger.ordered)
+		}
+
+		logger.mu.Unlock()
+		return
+	}
+
+	logger.ordered = nil
+	logger.mu.Unlock()
+
+	if o != nil {
+		o.shutdown()
+		o.wait()
+		close(o.stop)
+	}
+}
+
+// SetGoroutineID enables adding the ID of the logging goroutine as a
+// goroutine=<id> field after the prefix, or as the goroutine field in the
+// JSON format. It is disabled by default since looking up the ID is slow.
+func (logger *Logger) SetGoroutineID(enabled bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.goroutineID = enabled
+}
+
+// SetHost sets the host:port the logger connects to,
+// the current connection is closed so the next write dials host.
+// Writes in progress finish on the current connection.
+func (logger *Logger) SetHost(host string) {
+	logger.SetHosts(host)
+}
+
+// SetHosts is same as SetHost() but sets a list of hosts,
+// which are tried in order until one is reachable
+func (logger *Logger) SetHosts(hosts ...string) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.hosts = hosts
+	logger.hostIndex = 0
+
+	if logger.conn != nil {
+		logger.conn.Close()
+	}
+}
+
+// SetKeepAlive starts a background goroutine which calls Ping every interval,
+// the traffic keeps idle connections from being dropped by intermediaries
+// and dropped connections are reopened before the next log is written.
+// An interval of 0 stops the goroutine, which also stops on Close.
+func (logger *Logger) SetKeepAlive(interval time.Duration) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.keepAlive != nil {
+		close(logger.keepAlive.stop)
+		logger.keepAlive = nil
+	}
+
+	if interval > 0 && !logger.closed {
+		logger.keepAlive = &keepAlive{
+			stop: make(chan struct{}),
+			done: make(chan struct{}),
+		}
+		go logger.runKeepAlive(logger.keepAlive, interval)
+	}
+}
+
+// SetLineSeparator sets the line separator which terminates every line and
+// the replacement of the separators inside messages, e.g. " | " for
+// consumers which can't handle \u2028. Empty values restore the defaults,
+// which are "\n" and "\u2028".
+func (logger *Logger) SetLineSeparator(sep, replacement string) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.sep = sep
+	logger.replacement = replacement
+}
+
+// SetNormalizeCR enables treating \r\n and lone \r as line breaks,
+// e.g. for logs originating on Windows, so that multi-line messages stay
+// on a single event. It is disabled by default, which keeps \r as is.
+func (logger *Logger) SetNormalizeCR(enabled bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.normalizeCR = enabled
+}
+
+// SetOnError sets a function called with the error of every failed write,
+// e.g. to count or alert on them, also when the line is then spooled, queued
+// or written to the fallback. It is called without the logger lock held,
+// nil disables it.
+func (logger *Logger) SetOnError(onError func(err error)) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.onError = onError
+}
+
+// SetOnReconnect sets a function called after a new connection replaced a
+// broken one, from its own goroutine so it may log. nil disables it.
+func (logger *Logger) SetOnReconnect(onReconnect func()) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.onReconnect = onReconnect
+}
+
 // SetPrefix sets the logger prefix
 func (logger *Logger) SetPrefix(prefix string) {
 	logger.prefix = prefix
 }
 
-// Write writes a bytes array to the Logentries TCP connection,
-// it adds the access token and prefix and also replaces
-// line breaks with the unicode \u2028 character
-func (logger *Logger) Write(p []byte) (n int, err error) {
+// SetPreserveNewlines disables replacing the line breaks inside messages
+// with the unicode \u2028 character, so they are sent as is,
+// e.g. for plans supporting multi-line events. Long messages are still split.
+func (logger *Logger) SetPreserveNewlines(enabled bool) {
 	logger.mu.Lock()
-	if err := logger.ensureOpenConnection(); err != nil {
-		return 0, err
-	}
 	defer logger.mu.Unlock()
 
-	logger.makeBuf(p)
-
-	return logger.conn.Write(logger.buf)
+	logger.preserveNewlines = enabled
 }
 
-// makeBuf constructs the logger buffer
-// it is not safe to be used from within multiple concurrent goroutines
-func (logger *Logger) makeBuf(p []byte) {
-	count := strings.Count(string(p), lineSep)
-	p = []byte(strings.Replace(string(p), lineSep, "\u2028", count-1))
+// SetProcessFields adds the host name and the PID of the process as
+// hostname=<host> and pid=<pid> fields after the prefix of every line,
+// or as "hostname" and "pid" fields in the JSON format.
+// They are resolved once, the syslog format always includes them.
+func (logger *Logger) SetProcessFields(hostname, pid bool) {
+	var (
+		fields string
+		host   string
+		id     int
+	)
 
-	logger.buf = logger.buf[:0]
-	logger.buf = append(logger.buf, (logger.token + " ")...)
-	logger.buf = append(logger.buf, (logger.prefix + " ")...)
-	logger.buf = append(logger.buf, p...)
+	if hostname {
+		if name, err := os.Hostname(); err == nil {
+			host = name
+			fields += "hostname=" + name + " "
+		}
+	}
+
+	if pid {
+		id = os.Getpid()
+		fields += "pid=" + strconv.Itoa(id) + " "
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.processFields = fields
+	logger.processHost = host
+	logger.processPID = id
+}
+
+// SetRedaction replaces the matches of patterns in every message with
+// replacement before it is written, e.g. to mask secrets which were logged
+// by mistake. Messages are redacted before they are split.
+// $ signs in replacement are expanded as in regexp.Regexp.ReplaceAll.
+// No patterns disable the redaction.
+func (logger *Logger) SetRedaction(patterns []*regexp.Regexp, replacement string) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.redact = append([]*regexp.Regexp(nil), patterns...)
+	logger.redactWith = []byte(replacement)
+}
+
+// SetRethrowPanics decides what happens when writing panics, e.g. in a
+// custom connection. By default the panic is reported to the error output
+// and the write fails, so logging never takes down the program.
+// If enabled the panic is raised again after being reported.
+func (logger *Logger) SetRethrowPanics(enabled bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.rethrowPanics = enabled
+}
+
+// SetRetryQueue keeps up to capacity lines whose write failed in memory,
+// the queued lines are retried in the background with an exponential backoff.
+// policy decides what happens when the queue is full, a capacity of 0
+// disables the queue. It does nothing once the logger is closed.
+func (logger *Logger) SetRetryQueue(capacity int, policy OverflowPolicy) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.closed {
+		return
+	}
+
+	old := logger.queue
+	logger.queue = nil
+
+	if capacity > 0 {
+		logger.queue = newRetryQueue(capacity, policy)
+		go logger.runRetryQueue(logger.queue)
+	}
+
+	if old == nil {
+		return
+	}
+
+	close(old.stop)
+
+	// move the pending lines to the new queue
+	for {
+		select {
+		case line := <-old.lines:
+			if logger.queue == nil {
+				atomic.AddUint64(&logger.dropped, 1)
+				continue
+			}
+
+			select {
+			case logger.queue.lines <- line:
+			default:
+				atomic.AddUint64(&logger.dropped, 1)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// SetSeverityToken sets the access token of the messages logged with
+// severity, which routes them to a different log, e.g. for the errors.
+// An empty token restores the default token, see SetToken().
+// Messages logged by Print, Write and the like have SeverityInfo.
+func (logger *Logger) SetSeverityToken(severity Severity, token string) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if token == "" {
+		delete(logger.severityTokens, severity)
+		return
+	}
+
+	if logger.severityTokens == nil {
+		logger.severityTokens = make(map[Severity][]byte)
+	}
+	logger.severityTokens[severity] = []byte(token + " ")
+}
+
+// SetSpool stores lines which can't be written to logentries.com in a
+// file inside dir, the stored lines are replayed in order once the
+// connection is available again.
+// maxSize limits the spool file size in bytes, a maxSize of 0 means no limit,
+// policy decides which lines are discarded when the spool is full.
+// It returns an error if the logger is closed.
+func (logger *Logger) SetSpool(dir string, maxSize int64, policy OverflowPolicy) error {
+	s, err := newSpool(dir, maxSize, policy)
+	if err != nil {
+		return err
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.closed {
+		return ErrClosed
+	}
+
+	logger.spool = s
+
+	if logger.spoolStop == nil {
+		logger.spoolStop = make(chan struct{})
+		go logger.runSpool(logger.spoolStop)
+	}
+
+	return nil
+}
+
+// Stats returns the logger counters
+func (logger *Logger) Stats() Stats {
+	stats := Stats{
+		Dropped:    atomic.LoadUint64(&logger.dropped),
+		Split:      atomic.LoadUint64(&logger.split),
+		Sent:       atomic.LoadUint64(&logger.sent),
+		Errors:     atomic.LoadUint64(&logger.errors),
+		Reconnects: atomic.LoadUint64(&logger.reconnects),
+
+		ErrOutputFailures: atomic.LoadUint64(&logger.errFailed),
+	}
+
+	logger.mu.Lock()
+	if logger.queue != nil {
+		stats.QueueDepth = len(logger.queue.lines)
+	}
+	stats.BreakerOpen = logger.breaker != nil && logger.breaker.open(logger.now())
+	stats.LastSplitLength = logger.lastSplitLength
+	stats.LastSplitChunks = logger.lastSplitChunks
+	logger.mu.Unlock()
+
+	return stats
+}
+
+// SetStackTraces enables adding the stack trace of the caller to the
+// messages logged with SeverityError or a more severe severity,
+// including the Panic methods. It is added after the message,
+// or as the stack field in the JSON format.
+func (logger *Logger) SetStackTraces(enabled bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.stackTraces = enabled
+}
+
+// SetStripANSI enables removing ANSI escape codes, e.g. colors,
+// from the messages before they are written. It is disabled by default.
+func (logger *Logger) SetStripANSI(enabled bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.stripANSI = enabled
+}
+
+// SetTags adds the tags as key=value pairs after every message, sorted by
+// key so the output is stable. In the JSON format they are added as fields.
+// Values containing spaces, quotes or '=' are quoted.
+// A nil or empty map removes the tags.
+func (logger *Logger) SetTags(tags map[string]string) {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]tag, len(keys))
+	for i, key := range keys {
+		fields[i] = tag{key: key, value: tags[key]}
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.tags = formatTags(fields)
+	logger.tagFields = fields
+}
+
+// formatTags returns the tags as key=value pairs, each preceded by a space,
+// values containing spaces, quotes or '=' are quoted
+func formatTags(tags []tag) string {
+	var formatted string
+	for _, t := range tags {
+		value := t.value
+		if value == "" || strings.ContainsAny(value, " \"=") {
+			value = strconv.Quote(value)
+		}
+
+		formatted += " " + t.key + "=" + value
+	}
+
+	return formatted
+}
+
+// SetTee sets a writer which receives a copy of every line written to
+// logentries.com, including the access token and header, e.g. to keep a
+// local copy while migrating. Errors writing to w don't fail the write,
+// they are reported to the error output. A nil writer disables it.
+func (logger *Logger) SetTee(w io.Writer) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.tee = w
+}
+
+// SetTCPKeepAlive sets the TCP keep-alive period of the connections opened
+// by the logger, it takes effect on the next dial.
+// A period of 0 keeps the Go default and a negative period disables keep-alives.
+func (logger *Logger) SetTCPKeepAlive(period time.Duration) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.tcpKeepAlive = period
+}
+
+// SetTCPNoDelay sets TCP_NODELAY on the connections opened by the logger,
+// it takes effect on the next dial. Go enables it by default so small
+// lines are sent without delay, disabling it lets the kernel coalesce
+// them at the cost of latency.
+func (logger *Logger) SetTCPNoDelay(enabled bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.tcpDelay = !enabled
+}
+
+// SetClientCertificates sets the certificates presented to logentries.com,
+// for endpoints which require mutual TLS, e.g. loaded with
+// tls.LoadX509KeyPair. They replace the certificates of the TLS
+// configuration, see SetTLSConfig(), and take effect on the next dial.
+// No certificates restore the ones of the TLS configuration.
+func (logger *Logger) SetClientCertificates(certs ...tls.Certificate) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.clientCerts = certs
+}
+
+// SetServerName sets the name sent to the hosts for SNI and verified in
+// their certificates, e.g. when dialing a load balancer by IP.
+// It takes effect on the next dial, an empty name restores the host name.
+func (logger *Logger) SetServerName(name string) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.serverName = name
+}
+
+// SetTLSConfig sets the TLS configuration used to dial logentries.com,
+// e.g. for custom root CAs. It takes effect on the next dial,
+// a nil config restores the default.
+func (logger *Logger) SetTLSConfig(config *tls.Config) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.tlsConfig = config
+}
+
+// SetToken sets the access token used by the next writes,
+// it allows rotating the token without reconnecting
+func (logger *Logger) SetToken(token string) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.token = token
+}
+
+// SetTokenValidation enables validating the token with ValidateToken before
+// every dial, it is disabled by default for non-standard tokens
+func (logger *Logger) SetTokenValidation(enabled bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.validateToken = enabled
+}
+
+// Sync is same as Flush() but returns the error of writing the messages
+// batched in batching mode, it allows the logger to be used where a
+// Sync() error method is expected
+func (logger *Logger) Sync() error {
+	logger.flushDedup(-1)
+
+	logger.mu.Lock()
+	o, a := logger.ordered, logger.async
+	logger.mu.Unlock()
+
+	if o != nil {
+		o.wait()
+	}
+
+	if a != nil {
+		a.wait()
+	}
+
+	logger.mu.Lock()
+	if _, err := logger.flushBatchUnlock(time.Time{}); err != nil {
+		return err
+	}
+
+	// transports which batch the lines themselves are flushed too
+	logger.mu.Lock()
+
+	// the HTTP transport waits for its posts, which retry after a backoff,
+	// and hands the failed ones back to the logger, it is flushed unlocked
+	if c, ok := logger.conn.(*httpConn); ok {
+		logger.mu.Unlock()
+		return c.Flush()
+	}
+
+	defer logger.mu.Unlock()
+
+	if f, ok := logger.conn.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+// Write writes a bytes array to the Logentries TCP connection,
+// it adds the access token and prefix and also replaces
+// line breaks with the unicode \u2028 character, see SetLineSeparator().
+// If the write fails the line is stored in the spool or the retry queue,
+// if any is set, otherwise it is written to the fallback writer, if one is set.
+// As required by io.Writer n is at most len(p), it is len(p) on success.
+// The file:line of the Lshortfile and Llongfile flags isn't added since the
+// caller of Write is usually fmt or the log package, StdLogger() lets the
+// log package add the caller of its own methods.
+func (logger *Logger) Write(p []byte) (n int, err error) {
+	if logger.nop {
+		return len(p), nil
+	}
+
+	logger.mu.Lock()
+	if logger.drainingLocked() {
+		logger.mu.Unlock()
+		return 0, ErrClosed
+	}
+
+	// n counts the bytes of p, not the token and header written with them
+	if _, err = logger.writeUnlock(SeverityInfo, logger.header(SeverityInfo), logger.filter(p), time.Time{}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteString is same as Write() but writes a string,
+// without converting it to a new []byte
+func (logger *Logger) WriteString(s string) (n int, err error) {
+	if logger.nop {
+		return len(s), nil
+	}
+
+	logger.mu.Lock()
+	if logger.drainingLocked() {
+		logger.mu.Unlock()
+		return 0, ErrClosed
+	}
+
+	if _, err = logger.writeStringUnlock(SeverityInfo, logger.header(SeverityInfo), logger.filterString(s), time.Time{}); err != nil {
+		return 0, err
+	}
+
+	return len(s), nil
+}
+
+// WriteLines is same as Write() for each of lines, in order, but takes the
+// logger lock once and writes all the lines with a single write.
+// A line above the maximum message size is dropped as by Write(), the
+// other lines are still written and the first error is returned.
+func (logger *Logger) WriteLines(lines []string) error {
+	if logger.nop || len(lines) == 0 {
+		return nil
+	}
+
+	logger.mu.Lock()
+	if logger.drainingLocked() || logger.closed {
+		logger.mu.Unlock()
+		return ErrClosed
+	}
+
+	header := logger.header(SeverityInfo)
+	tokenPrefix := logger.severityTokenPrefix(SeverityInfo)
+
+	buf := getBuf()
+	defer putBuf(buf)
+
+	var firstErr error
+	n, messages := 0, 0
+	for _, line := range lines {
+		p, err := logger.limitSize(logger.filter([]byte(line)))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		*buf = logger.appendLines(*buf, tokenPrefix, header, p)
+		n += len(line)
+		messages++
+	}
+
+	if messages == 0 {
+		logger.mu.Unlock()
+		return firstErr
+	}
+
+	logger.teeLines(net.Buffers{*buf})
+
+	var err error
+	if logger.batch != nil {
+		_, err = logger.batchUnlock(*buf, messages, n, logger.flushes(SeverityInfo), time.Time{})
+	} else {
+		_, err = logger.sendUnlock(*buf, messages, time.Time{})
+	}
+
+	if firstErr == nil {
+		firstErr = err
+	}
+
+	return firstErr
+}
+
+// WriteRaw writes the pre-formatted message p, e.g. a JSON line forwarded
+// verbatim, to the Logentries TCP connection. Only the access token is
+// added, not the header nor the tags, the line breaks are handled and
+// long messages are split as by Write().
+func (logger *Logger) WriteRaw(p []byte) (n int, err error) {
+	if logger.nop {
+		return len(p), nil
+	}
+
+	logger.mu.Lock()
+	if logger.drainingLocked() {
+		logger.mu.Unlock()
+		return 0, ErrClosed
+	}
+
+	if _, err = logger.writeUnlock(SeverityInfo, "", logger.filter(p), time.Time{}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// writeStringUnlock is same as writeUnlock() but writes a string,
+// it is copied into a pooled buffer.
+// It must be called with the logger lock held, it releases the lock.
+func (logger *Logger) writeStringUnlock(severity Severity, header, s string, deadline time.Time) (int, error) {
+	// an oversized message isn't copied
+	s, err := logger.limitSizeString(s, 0)
+	if err != nil {
+		logger.mu.Unlock()
+		return 0, err
+	}
+
+	p := getBuf()
+	defer putBuf(p)
+
+	*p = append(*p, s...)
+
+	return logger.writeUnlock(severity, header, *p, deadline)
+}
+
+// writeUnlock is same as Write() but must be called with the logger
+// lock held, it releases the lock. The functions named ...Unlock release
+// the lock, unlike the ...Locked ones which keep it held.
+// header is written after the token of every line, which is the token
+// of severity, and deadline is the write deadline, zero means no deadline
+func (logger *Logger) writeUnlock(severity Severity, header string, p []byte, deadline time.Time) (n int, err error) {
+	if logger.closed {
+		logger.mu.Unlock()
+		return 0, ErrClosed
+	}
+
+	if p, err = logger.limitSize(p); err != nil {
+		logger.mu.Unlock()
+		return 0, err
+	}
+
+	// TCP connections write the lines without copying them into a buffer
+	if _, ok := logger.conn.(*net.TCPConn); ok && logger.batch == nil {
+		bufs := logger.makeBuffers(logger.severityTokenPrefix(severity), header, p)
+		logger.teeLines(bufs)
+
+		return logger.sendBuffersUnlock(bufs, deadline)
+	}
+
+	buf := getBuf()
+	defer putBuf(buf)
+
+	*buf = logger.appendLines(*buf, logger.severityTokenPrefix(severity), header, p)
+	logger.teeLines(net.Buffers{*buf})
+
+	if logger.batch != nil {
+		return logger.batchUnlock(*buf, 1, len(p), logger.flushes(severity), deadline)
+	}
+
+	return logger.sendUnlock(*buf, 1, deadline)
+}
+
+// teeLines writes a copy of the lines to the tee writer, if one is set,
+// errors and panics are reported to the error output
+func (logger *Logger) teeLines(bufs net.Buffers) {
+	if logger.tee == nil {
+		return
+	}
+
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = &writePanic{value: r}
+		}
+
+		if err != nil {
+			logger.errorf("writing to the tee: %v", err)
+		}
+	}()
+
+	// writing consumes the buffers, write a copy
+	bufs = append(net.Buffers(nil), bufs...)
+	_, err = bufs.WriteTo(logger.tee)
+}
+
+// sendUnlock writes b, the lines of the given number of messages, to the
+// TCP connection after the spooled lines, a failed write is retried as the
+// retry policy allows, see retryUnlock(). b is handed to writeFailed if
+// the connection can't be opened. The messages are counted as sent once
+// written.
+// It must be called with the logger lock held, it releases the lock.
+func (logger *Logger) sendUnlock(b []byte, messages int, deadline time.Time) (n int, err error) {
+	logger.startWrite()
+
+	// spooled lines are replayed first to preserve the lines order
+	if err = logger.replaySpool(); err == nil {
+		err = logger.ensureOpenConnection()
+	}
+
+	if err != nil {
+		line := append([]byte(nil), b...)
+		logger.endWrite()
+		logger.mu.Unlock()
+
+		return logger.writeFailed(line, err)
+	}
+
+	if n, err = writeWithDeadline(logger.conn, b, deadline); err == nil {
+		atomic.AddUint64(&logger.sent, uint64(messages))
+		logger.endWrite()
+		logger.mu.Unlock()
+		return n, nil
+	}
+
+	logger.dropConn()
+	logger.endWrite()
+
+	return logger.retryUnlock(append([]byte(nil), b...), messages, deadline, err)
+}
+
+// sendBuffersUnlock is same as sendUnlock() but writes bufs, the lines of
+// a single message, with a single writev, if the write fails the lines are
+// retried as a single buffer.
+// It must be called with the logger lock held, it releases the lock.
+func (logger *Logger) sendBuffersUnlock(bufs net.Buffers, deadline time.Time) (n int, err error) {
+	logger.startWrite()
+
+	// spooled lines are replayed first to preserve the lines order
+	if err = logger.replaySpool(); err == nil {
+		err = logger.ensureOpenConnection()
+	}
+
+	if err != nil {
+		logger.endWrite()
+		logger.mu.Unlock()
+
+		return logger.writeFailed(flatten(bufs), err)
+	}
+
+	// writing consumes the buffers, keep bufs for building the line
+	pending := append(net.Buffers(nil), bufs...)
+
+	var written int64
+	if written, err = writeBuffersWithDeadline(logger.conn, pending, deadline); err == nil {
+		atomic.AddUint64(&logger.sent, 1)
+		logger.endWrite()
+		logger.mu.Unlock()
+		return int(written), nil
+	}
+
+	logger.dropConn()
+	logger.endWrite()
+
+	return logger.retryUnlock(flatten(bufs), 1, deadline, err)
+}
+
+// retryUnlock retries the write of line, the lines of the given number of
+// messages, which failed with err. The write is attempted again as long as
+// the retry policy allows, after its backoff, which is waited without the
+// lock, and it isn't retried past deadline. It reconnects first if the
+// connection is closed, a failed reconnection ends the retries.
+// A write which panicked isn't retried, nor any if a spool or a retry queue
+// is set, which retry the line in the background. line is handed to
+// writeFailed if it can't be written.
+// It must be called with the logger lock held, it releases the lock.
+func (logger *Logger) retryUnlock(line []byte, messages int, deadline time.Time, err error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		if _, ok := err.(*writePanic); ok || err == ErrClosed {
+			break
+		}
+
+		if logger.spool != nil || logger.queue != nil {
+			break
+		}
+
+		backoff, retry := logger.retryPolicy().NextBackoff(attempt)
+		if !retry || (!deadline.IsZero() && !logger.now().Add(backoff).Before(deadline)) {
+			break
+		}
+
+		logger.mu.Unlock()
+		<-logger.after(backoff)
+		logger.mu.Lock()
+
+		logger.startWrite()
+		if err = logger.ensureOpenConnection(); err != nil {
+			logger.endWrite()
+			break
+		}
+
+		var n int
+		if n, err = writeWithDeadline(logger.conn, line, deadline); err == nil {
+			atomic.AddUint64(&logger.sent, uint64(messages))
+			logger.endWrite()
+			logger.mu.Unlock()
+			return n, nil
+		}
+
+		logger.dropConn()
+		logger.endWrite()
+	}
+
+	logger.mu.Unlock()
+
+	if err == ErrClosed {
+		return 0, err
+	}
+
+	return logger.writeFailed(line, err)
+}
+
+// startWrite records the start of a write, see WriteStartedAt()
+func (logger *Logger) startWrite() {
+	atomic.StoreInt64(&logger.writeStarted, logger.now().UnixNano())
+}
+
+// endWrite records the end of the write in progress
+func (logger *Logger) endWrite() {
+	atomic.StoreInt64(&logger.writeStarted, 0)
+}
+
+// WriteStartedAt returns when the write to the connection in progress
+// started, or the zero time if no write is in progress. A write which
+// started long ago is stuck, e.g. on an unresponsive connection, and holds
+// the logger lock meanwhile, see OutputContext() for a write deadline.
+func (logger *Logger) WriteStartedAt() time.Time {
+	started := atomic.LoadInt64(&logger.writeStarted)
+	if started == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, started)
+}
+
+// flatten returns the content of bufs as a single buffer
+func flatten(bufs net.Buffers) []byte {
+	var b []byte
+	for _, buf := range bufs {
+		b = append(b, buf...)
+	}
+
+	return b
+}
+
+// writeFailed hands a line whose write failed to the spool, the retry queue
+// or the fallback writer, in that order.
+// the logger lock must not be held since pushing to the queue may block
+func (logger *Logger) writeFailed(line []byte, err error) (int, error) {
+	atomic.AddUint64(&logger.errors, 1)
+
+	logger.mu.Lock()
+	s, q, rethrow, onError := logger.spool, logger.queue, logger.rethrowPanics, logger.onError
+	logger.mu.Unlock()
+
+	if onError != nil {
+		onError(err)
+	}
+
+	if p, ok := err.(*writePanic); ok {
+		logger.errorf("recovered from a panic while writing: %v", p.value)
+
+		if rethrow {
+			panic(p.value)
+		}
+	}
+
+	if s != nil && s.append(line) == nil {
+		return len(line), nil
+	}
+
+	for q != nil {
+		dropped, queueErr := q.push(line)
+		atomic.AddUint64(&logger.dropped, uint64(dropped))
+
+		if queueErr == nil {
+			return len(line), nil
+		}
+
+		if queueErr != errQueueStopped {
+			break
+		}
+
+		// the queue was replaced while blocking, push to the new one
+		logger.mu.Lock()
+		if logger.queue == q {
+			q = nil
+		} else {
+			q = logger.queue
+		}
+		logger.mu.Unlock()
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.fallback != nil {
+		return logger.fallback.Write(line)
+	}
+
+	if err == errCircuitOpen {
+		atomic.AddUint64(&logger.dropped, 1)
+	}
+
+	return 0, err
+}
+
+// writeConn writes b to the TCP connection, which is opened first if it
+// is closed, it makes a single attempt, see retryUnlock().
+// deadline is the write deadline, zero means no deadline.
+// it is not safe to be used from within multiple concurrent goroutines
+func (logger *Logger) writeConn(b []byte, deadline time.Time) (int, error) {
+	if err := logger.ensureOpenConnection(); err != nil {
+		return 0, err
+	}
+
+	n, err := writeWithDeadline(logger.conn, b, deadline)
+	if err != nil {
+		logger.dropConn()
+	}
+
+	return n, err
+}
+
+// dropConn closes the connection after a failed write so the next write
+// reconnects, the connection may have been dropped while idle or broken by
+// a timeout and the liveness check can't tell. A connection passed by the
+// user, which the logger can't reopen, is left open.
+// the logger lock must be held
+func (logger *Logger) dropConn() {
+	if logger.conn != nil && (!logger.fixedConn || logger.dial != nil) {
+		logger.conn.Close()
+	}
+}
+
+// writeWithDeadline writes b to conn with the deadline set only for
+// the duration of the write, a panic in conn is returned as a *writePanic
+func writeWithDeadline(conn net.Conn, b []byte, deadline time.Time) (n int, err error) {
+	defer recoverWrite(&err)
+
+	if deadline.IsZero() {
+		return conn.Write(b)
+	}
+
+	conn.SetWriteDeadline(deadline)
+	defer conn.SetWriteDeadline(time.Time{})
+
+	return conn.Write(b)
+}
+
+// writeBuffersWithDeadline is same as writeWithDeadline() but writes bufs
+func writeBuffersWithDeadline(conn net.Conn, bufs net.Buffers, deadline time.Time) (n int64, err error) {
+	defer recoverWrite(&err)
+
+	if deadline.IsZero() {
+		return bufs.WriteTo(conn)
+	}
+
+	conn.SetWriteDeadline(deadline)
+	defer conn.SetWriteDeadline(time.Time{})
+
+	return bufs.WriteTo(conn)
+}
+
+// writePanic is the error returned when a write panics
+type writePanic struct {
+	value interface{}
+}
+
+func (p *writePanic) Error() string {
+	return fmt.Sprintf("le_go: panic while writing: %v", p.value)
+}
+
+// recoverWrite recovers from a panic while writing,
+// setting err to a *writePanic. It must be deferred
+func recoverWrite(err *error) {
+	if r := recover(); r != nil {
+		*err = &writePanic{value: r}
+	}
+}
+
+// replaySpool writes all the spooled lines to the TCP connection.
+// it is not safe to be used from within multiple concurrent goroutines
+func (logger *Logger) replaySpool() error {
+	if logger.spool == nil || logger.spool.empty() {
+		return nil
+	}
+
+	if err := logger.ensureOpenConnection(); err != nil {
+		return err
+	}
+
+	return logger.spool.replay(func(line []byte) error {
+		_, err := writeWithDeadline(logger.conn, line, time.Time{})
+		if err == nil {
+			atomic.AddUint64(&logger.sent, 1)
+		}
+
+		return err
+	})
+}
+
+// runSpool periodically replays the spool until stop is closed
+func (logger *Logger) runSpool(stop <-chan struct{}) {
+	ticker := time.NewTicker(spoolInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			logger.mu.Lock()
+			logger.replaySpool()
+			logger.mu.Unlock()
+		}
+	}
+}
+
+// runKeepAlive pings the connection every interval until k is stopped
+func (logger *Logger) runKeepAlive(k *keepAlive, interval time.Duration) {
+	defer close(k.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+			logger.Ping()
+		}
+	}
+}
+
+// errorf writes a diagnostic message to the error output
+func (logger *Logger) errorf(format string, v ...interface{}) {
+	logger.errMu.Lock()
+	defer logger.errMu.Unlock()
+
+	msg := fmt.Sprintf("le_go: "+format+"\n", v...)
+
+	w := logger.errWriter()
+	if _, err := io.WriteString(w, msg); err != nil {
+		atomic.AddUint64(&logger.errFailed, 1)
+
+		// os.Stderr is the last resort to keep the diagnostics visible
+		if w != os.Stderr {
+			fmt.Fprintf(os.Stderr, "le_go: writing to the error output: %v\n%s", err, msg)
+		}
+	}
+}
+
+// errWriter returns the error output, it defaults to os.Stderr so the
+// diagnostics don't mix with the program output.
+// errMu must be held
+func (logger *Logger) errWriter() io.Writer {
+	if logger.errOutput == nil {
+		return os.Stderr
+	}
+
+	return logger.errOutput
+}
+
+// makeBuf appends the lines of the message p to buf and returns the result,
+// every line starts with the access token and header.
+// it is not safe to be used from within multiple concurrent goroutines
+func (logger *Logger) makeBuf(buf []byte, header string, p []byte) []byte {
+	return logger.appendLines(buf, logger.tokenPrefix(), header, p)
+}
+
+// appendLines is same as makeBuf() but the lines start with tokenPrefix
+func (logger *Logger) appendLines(buf, tokenPrefix []byte, header string, p []byte) []byte {
+	p = logger.message(p, header != "")
+	sep := logger.lineSep()
+	chunks := 0
+
+	// messages longer than maxLogLength are split into multiple lines,
+	// each line starts with the access token and header
+	for rest := p; ; {
+		chunk := nextChunk(rest)
+
+		buf = append(buf, tokenPrefix...)
+		buf = append(buf, header...)
+		buf = append(buf, chunk...)
+		buf = append(buf, sep...)
+		chunks++
+
+		if rest = rest[len(chunk):]; len(rest) == 0 {
+			break
+		}
+	}
+
+	logger.countSplit(len(p), chunks)
+
+	return buf
+}
+
+// makeBuffers is same as appendLines() but returns the token, header and
+// message slices of the lines without copying them into a single buffer
+func (logger *Logger) makeBuffers(tokenPrefix []byte, header string, p []byte) net.Buffers {
+	p = logger.message(p, header != "")
+	prefix := []byte(header)
+	sep := []byte(logger.lineSep())
+
+	var bufs net.Buffers
+	for rest := p; ; {
+		chunk := nextChunk(rest)
+
+		bufs = append(bufs, tokenPrefix, prefix, chunk, sep)
+
+		if rest = rest[len(chunk):]; len(rest) == 0 {
+			break
+		}
+	}
+
+	logger.countSplit(len(p), len(bufs)/4)
+
+	return bufs
+}
+
+// header returns the header of the lines of a message logged with severity,
+// which is the prefix, the severity, the timestamp, the process fields and
+// the goroutine ID, if enabled, unless a format is set
+func (logger *Logger) header(severity Severity) string {
+	if logger.syslog != nil {
+		return logger.syslog.header(severity, logger.prefix, logger.now())
+	}
+
+	header := logger.prefix + " " + logger.headerSeverity(severity, SeverityBeforeTimestamp)
+	if logger.timestamped() {
+		header += string(logger.appendTimestamp(nil, logger.now()))
+	}
+	header += logger.headerSeverity(severity, SeverityAfterTimestamp) + logger.processFields
+
+	if logger.goroutineID {
+		header += "goroutine=" + strconv.FormatUint(goroutineID(), 10) + " "
+	}
+
+	return header
+}
+
+// goroutineID returns the ID of the calling goroutine,
+// which is parsed from the first line of its stack, "goroutine N [running]:"
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+
+	return id
+}
+
+// filter returns the message text p without ANSI escape codes if
+// stripANSI is set, and redacted. It is applied before the message is
+// formatted, e.g. encoded as JSON, so the patterns match the text as logged.
+// the logger lock must be held
+func (logger *Logger) filter(p []byte) []byte {
+	if logger.stripANSI && bytes.IndexByte(p, 0x1b) >= 0 {
+		p = ansiPattern.ReplaceAll(p, nil)
+	}
+
+	for _, pattern := range logger.redact {
+		p = pattern.ReplaceAll(p, logger.redactWith)
+	}
+
+	return p
+}
+
+// filterString is same as filter() but filters a string,
+// which is returned as is if there is nothing to filter
+func (logger *Logger) filterString(s string) string {
+	if len(logger.redact) == 0 && (!logger.stripANSI || strings.IndexByte(s, 0x1b) < 0) {
+		return s
+	}
+
+	return string(logger.filter([]byte(s)))
+}
+
+// filterFields returns fields with filtered values
+func (logger *Logger) filterFields(fields []tag) []tag {
+	if len(fields) == 0 || (len(logger.redact) == 0 && !logger.stripANSI) {
+		return fields
+	}
+
+	filtered := make([]tag, len(fields))
+	for i, t := range fields {
+		filtered[i] = tag{key: t.key, value: logger.filterString(t.value)}
+	}
+
+	return filtered
+}
+
+// message returns the message p as written, normalized and followed by
+// the tags if tagged, which is false for raw messages.
+// p is filtered beforehand, see filter()
+func (logger *Logger) message(p []byte, tagged bool) []byte {
+	p = logger.normalize(p)
+
+	if logger.tags != "" && !logger.json && tagged {
+		// p may be owned by the caller, never append to it in place
+		p = append(p[:len(p):len(p)], logger.tags...)
+	}
+
+	return p
+}
+
+// normalize replaces the line separators inside the message p with the
+// replacement, unless preserveNewlines is set,
+// and removes the trailing line separator.
+// \r\n and \r are treated as line breaks if normalizeCR is set
+func (logger *Logger) normalize(p []byte) []byte {
+	sep := []byte(logger.lineSep())
+
+	if logger.normalizeCR && bytes.IndexByte(p, '\r') >= 0 {
+		p = bytes.Replace(p, []byte("\r\n"), sep, -1)
+		p = bytes.Replace(p, []byte("\r"), sep, -1)
+	}
+
+	// a single trailing line break is dropped, every other one is replaced.
+	// p isn't copied unless it has line breaks to replace
+	p = bytes.TrimSuffix(p, sep)
+	if !logger.preserveNewlines && bytes.Contains(p, sep) {
+		p = bytes.Replace(p, sep, []byte(logger.lineSepReplacement()), -1)
+	}
+
+	return p
+}
+
+// lineSep returns the line separator
+func (logger *Logger) lineSep() string {
+	if logger.sep == "" {
+		return lineSep
+	}
+
+	return logger.sep
+}
+
+// lineSepReplacement returns the replacement of the line separators
+// inside messages
+func (logger *Logger) lineSepReplacement() string {
+	if logger.replacement == "" {
+		return lineSepReplacement
+	}
+
+	return logger.replacement
+}
+
+// countSplit records a message of length bytes which was split
+// into the given number of lines
+func (logger *Logger) countSplit(length, chunks int) {
+	if chunks > 1 {
+		atomic.AddUint64(&logger.split, 1)
+		logger.lastSplitLength = length
+		logger.lastSplitChunks = chunks
+	}
+}
+
+// tokenPrefix returns the access token followed by a space,
+// it is cached and rebuilt only when the token changes
+func (logger *Logger) tokenPrefix() []byte {
+	if logger.omitToken {
+		return nil
+	}
+
+	if logger.tokenBytes == nil || logger.tokenBytesOf != logger.token {
+		logger.tokenBytes = []byte(logger.token + " ")
+		logger.tokenBytesOf = logger.token
+	}
+
+	return logger.tokenBytes
+}
+
+// severityTokenPrefix returns the access token of severity followed by
+// a space, which is the token set by SetSeverityToken or the default one
+func (logger *Logger) severityTokenPrefix(severity Severity) []byte {
+	if token, ok := logger.severityTokens[severity]; ok && !logger.omitToken {
+		return token
+	}
+
+	return logger.tokenPrefix()
+}
+
+// nextChunk returns the first chunk of p which fits in a single line
+func nextChunk(p []byte) []byte {
+	if len(p) > maxLogLength {
+		return p[:chunkLen(p)]
+	}
+
+	return p
+}
+
+// chunkLen returns the length of the first chunk of p,
+// it doesn't split UTF-8 encoded characters
+func chunkLen(p []byte) int {
+	for n := maxLogLength; n > 0; n-- {
+		if utf8.RuneStart(p[n]) {
+			return n
+		}
+	}
+
+	return maxLogLength
+}
+
+// getBuf returns an empty buffer from the pool
+func getBuf() *[]byte {
+	buf := bufPool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+
+	return buf
+}
 
-	if !strings.HasSuffix(string(logger.buf), lineSep) {
-		logger.buf = append(logger.buf, (lineSep)...)
+// putBuf returns buf to the pool unless it grew too large
+func putBuf(buf *[]byte) {
+	if cap(*buf) <= maxPooledBufSize {
+		bufPool.Put(buf)
 	}
 }
@@ -1,12 +1,120 @@
 package le_go
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// fakeTimeoutError is returned by fakeConnection reads so that
+// isOpenConnection considers the connection open
+type fakeTimeoutError struct{}
+
+func (fakeTimeoutError) Error() string   { return "i/o timeout" }
+func (fakeTimeoutError) Timeout() bool   { return true }
+func (fakeTimeoutError) Temporary() bool { return true }
+
+// fakeConnection is an in-memory net.Conn which records every write
+type fakeConnection struct {
+	mu         sync.Mutex
+	writes     [][]byte
+	failWrites int
+	delay      time.Duration
+	closed     bool
+}
+
+func (c *fakeConnection) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, io.EOF
+	}
+
+	return 0, fakeTimeoutError{}
+}
+
+func (c *fakeConnection) Write(b []byte) (int, error) {
+	time.Sleep(c.delay)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, errors.New("use of closed connection")
+	}
+
+	if c.failWrites > 0 {
+		c.failWrites--
+		return 0, errors.New("broken pipe")
+	}
+
+	c.writes = append(c.writes, append([]byte(nil), b...))
+
+	return len(b), nil
+}
+
+func (c *fakeConnection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	return nil
+}
+
+func (c *fakeConnection) Written() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([][]byte(nil), c.writes...)
+}
+
+// failing returns if the next write of c fails
+func (c *fakeConnection) failing() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.failWrites > 0
+}
+
+// redial returns a DialFunc which reopens c
+func (c *fakeConnection) redial() DialFunc {
+	return func() (net.Conn, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.closed = false
+
+		return c, nil
+	}
+}
+
+func (c *fakeConnection) LocalAddr() net.Addr                { return nil }
+func (c *fakeConnection) RemoteAddr() net.Addr               { return nil }
+func (c *fakeConnection) SetDeadline(t time.Time) error      { return nil }
+func (c *fakeConnection) SetReadDeadline(t time.Time) error  { return nil }
+func (c *fakeConnection) SetWriteDeadline(t time.Time) error { return nil }
+
 func TestConnectOpensConnection(t *testing.T) {
 	le, err := Connect("")
 	if err != nil {
@@ -37,6 +145,167 @@ func TestConnectSetsToken(t *testing.T) {
 	}
 }
 
+func TestConnectLazyDialsOnFirstWrite(t *testing.T) {
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	if le.conn != nil {
+		t.Fatal("expected no connection before the first write")
+	}
+
+	// nothing listens on port 1
+	le.hosts = []string{"127.0.0.1:1"}
+
+	_, err := le.Write([]byte("test"))
+	if !isNotConnected(err) {
+		t.Fatalf("expected ErrNotConnected, got %v", err)
+	}
+
+	if _, ok := err.(*notConnectedError).Unwrap().(*net.OpError); !ok {
+		t.Fatalf("expected a dial error, got %v", err)
+	}
+}
+
+// isNotConnected is same as errors.Is(err, ErrNotConnected),
+// which isn't available before Go 1.13
+func isNotConnected(err error) bool {
+	e, ok := err.(interface{ Is(error) bool })
+	return ok && e.Is(ErrNotConnected)
+}
+
+func TestWriteAfterCloseReturnsErrClosed(t *testing.T) {
+	le := Logger{conn: &fakeConnection{}, token: "myToken"}
+	le.Close()
+
+	if _, err := le.Write([]byte("test")); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+
+	if err := le.Print("test"); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestNewWithConnWritesToConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	le := NewWithConn(client, "myToken")
+	defer le.Close()
+
+	lines := make(chan string)
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := server.Read(buf)
+		lines <- string(buf[:n])
+	}()
+
+	if err := le.Print("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if line := <-lines; line != "myToken  test\n" {
+		t.Fatalf("unexpected line %q", line)
+	}
+}
+
+func TestNewWithConnDoesNotReconnect(t *testing.T) {
+	client, server := net.Pipe()
+	server.Close()
+
+	le := NewWithConn(client, "myToken")
+	defer le.Close()
+
+	if _, err := le.Write([]byte("test")); err != errFixedConn {
+		t.Fatalf("expected errFixedConn, got %v", err)
+	}
+}
+
+func TestDialFuncIsUsedOnReconnect(t *testing.T) {
+	var conns []*fakeConnection
+
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	le.SetDialFunc(func() (net.Conn, error) {
+		conn := &fakeConnection{}
+		conns = append(conns, conn)
+		return conn, nil
+	})
+
+	le.Print("1")
+
+	if len(conns) != 1 || len(conns[0].Written()) != 1 {
+		t.Fatal("expected the first write to dial")
+	}
+
+	conns[0].Close()
+	le.Print("2")
+
+	if len(conns) != 2 || len(conns[1].Written()) != 1 {
+		t.Fatal("expected the closed connection to be redialed")
+	}
+}
+
+func TestRegionHost(t *testing.T) {
+	host, err := RegionEU.Host()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if host != "eu.data.logs.insight.rapid7.com:443" {
+		t.Fatalf("unexpected host %q", host)
+	}
+
+	if host, _ := RegionLogentries.Host(); host != defaultHost {
+		t.Fatalf("unexpected host %q", host)
+	}
+
+	if _, err := ConnectRegion(Region("mars"), "myToken"); err == nil {
+		t.Fatal("expected an unknown region error")
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	if err := ValidateToken("2bfbea1e-10c3-4419-bdad-7e6435882e1f"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{"", "myToken", "2bfbea1e-10c3-4419-bdad"} {
+		if ValidateToken(token) == nil {
+			t.Errorf("expected %q to be rejected", token)
+		}
+	}
+}
+
+func TestConnectWithTokenValidationRejectsEmptyToken(t *testing.T) {
+	_, err := Connect("", WithTokenValidation())
+	if err == nil || err.Error() != "le_go: empty token" {
+		t.Fatalf("expected an empty token error, got %v", err)
+	}
+}
+
+func TestTokenValidationRejectsEmptyTokenBeforeDialing(t *testing.T) {
+	dials := 0
+
+	le := ConnectLazy("")
+	defer le.Close()
+
+	le.SetTokenValidation(true)
+	le.SetDialFunc(func() (net.Conn, error) {
+		dials++
+		return &fakeConnection{}, nil
+	})
+
+	if _, err := le.Write([]byte("test")); err == nil {
+		t.Fatal("expected the empty token to be rejected")
+	}
+
+	if dials != 0 {
+		t.Fatal("expected no dial")
+	}
+}
+
 func TestCloseClosesConnection(t *testing.T) {
 	le, err := Connect("")
 	if err != nil {
@@ -50,6 +319,294 @@ func TestCloseClosesConnection(t *testing.T) {
 	}
 }
 
+func TestCloseIsIdempotent(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+
+	if err := le.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := le.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := le.Write([]byte("test")); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+
+	if err := le.Print("test"); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestCloseWritesPendingMessages(t *testing.T) {
+	conn := &fakeConnection{delay: 50 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+
+	le.SetOrdered(true)
+	le.Print("1")
+	le.Print("2")
+
+	le.Close()
+
+	if len(conn.Written()) != 2 {
+		t.Fatalf("expected 2 writes, got %d", len(conn.Written()))
+	}
+}
+
+func TestIsConnected(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+
+	if !le.IsConnected() {
+		t.Fatal("expected the logger to be connected")
+	}
+
+	le.Close()
+
+	if le.IsConnected() {
+		t.Fatal("expected the logger to be disconnected")
+	}
+
+	if len(conn.Written()) != 0 {
+		t.Fatal("expected no writes")
+	}
+}
+
+func TestPing(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+
+	if err := le.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := conn.Written()
+	if len(writes) != 1 || string(writes[0]) != "myToken \n" {
+		t.Fatalf("unexpected writes %q", writes)
+	}
+
+	conn.failWrites = 2
+
+	if err := le.Ping(); err == nil {
+		t.Fatal("expected the ping to fail")
+	}
+}
+
+func TestReconnectNow(t *testing.T) {
+	conn := &fakeConnection{}
+	dials := 0
+	le := Logger{conn: conn, token: "myToken", dial: func() (net.Conn, error) {
+		dials++
+		return conn.redial()()
+	}}
+
+	if err := le.ReconnectNow(); err != nil {
+		t.Fatal(err)
+	}
+
+	if dials != 1 || le.Stats().Reconnects != 1 {
+		t.Fatalf("expected a new connection, got %d dials", dials)
+	}
+
+	le.Print("test")
+
+	if writes := conn.Written(); len(writes) != 1 {
+		t.Fatalf("expected the new connection to be written to, got %q", writes)
+	}
+
+	le.Close()
+
+	if err := le.ReconnectNow(); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestConnectionAge(t *testing.T) {
+	conn := &fakeConnection{}
+	clock := newFakeClock()
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial(), clock: clock}
+	defer le.Close()
+
+	if age := le.ConnectionAge(); age != 0 {
+		t.Fatalf("expected no age for a connection of unknown age, got %v", age)
+	}
+
+	le.ReconnectNow()
+	clock.Advance(time.Minute)
+
+	if age := le.ConnectionAge(); age != time.Minute {
+		t.Fatalf("expected an age of 1m, got %v", age)
+	}
+
+	le.ReconnectNow()
+
+	if age := le.ConnectionAge(); age != 0 {
+		t.Fatalf("expected the reconnect to reset the age, got %v", age)
+	}
+
+	clock.Advance(time.Minute)
+	le.SetConn(&fakeConnection{}, true)
+	clock.Advance(time.Second)
+
+	if age := le.ConnectionAge(); age != time.Second {
+		t.Fatalf("expected SetConn to reset the age, got %v", age)
+	}
+
+	fixed := NewWithConn(&fakeConnection{}, "myToken")
+	defer fixed.Close()
+	time.Sleep(time.Millisecond)
+
+	if age := fixed.ConnectionAge(); age <= 0 || age > time.Minute {
+		t.Fatalf("expected NewWithConn to set the age, got %v", age)
+	}
+}
+
+func TestLongLivedConnectionIsKept(t *testing.T) {
+	conn := &fakeConnection{}
+	clock := newFakeClock()
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial(), clock: clock}
+	defer le.Close()
+
+	le.ReconnectNow()
+	le.Print("1")
+	clock.Advance(16 * time.Minute)
+	le.Print("2")
+
+	if reconnects := le.Stats().Reconnects; reconnects != 1 {
+		t.Fatalf("expected an old open connection to be kept, got %d reconnects", reconnects)
+	}
+
+	if age := le.ConnectionAge(); age != 16*time.Minute {
+		t.Fatalf("expected an age of 16m, got %v", age)
+	}
+
+	le.ReconnectNow()
+
+	if age := le.ConnectionAge(); age != 0 {
+		t.Fatalf("expected the reconnect to reset the age, got %v", age)
+	}
+
+	clock.Advance(time.Minute)
+	le.SetConn(&fakeConnection{}, true)
+	clock.Advance(time.Second)
+
+	if age := le.ConnectionAge(); age != time.Second {
+		t.Fatalf("expected SetConn to reset the age, got %v", age)
+	}
+
+	fixed := NewWithConn(&fakeConnection{}, "myToken")
+	defer fixed.Close()
+	time.Sleep(time.Millisecond)
+
+	if age := fixed.ConnectionAge(); age <= 0 || age > time.Minute {
+		t.Fatalf("expected NewWithConn to set the age, got %v", age)
+	}
+}
+
+// talkingConnection is a fakeConnection from which the server sent data
+type talkingConnection struct {
+	*fakeConnection
+	data []byte
+}
+
+func (c *talkingConnection) Read(b []byte) (int, error) {
+	if len(c.data) == 0 {
+		return c.fakeConnection.Read(b)
+	}
+
+	n := copy(b, c.data)
+	c.data = c.data[n:]
+
+	return n, nil
+}
+
+func TestIsOpenConnectionKeepsServerData(t *testing.T) {
+	conn := &talkingConnection{fakeConnection: &fakeConnection{}, data: []byte("hello")}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	if !le.isOpenConnection() || !le.isOpenConnection() {
+		t.Fatal("expected a connection with server data to be open")
+	}
+
+	le.Print("test")
+
+	if writes := conn.Written(); len(writes) != 1 || le.Stats().Reconnects != 0 {
+		t.Fatalf("expected the connection to be written to, got %q", writes)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(le.conn, 5))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected the server data to be kept, got %q, %v", data, err)
+	}
+}
+
+func TestIsOpenConnectionProbesAfterServerData(t *testing.T) {
+	conn := &talkingConnection{fakeConnection: &fakeConnection{}, data: []byte("hello")}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	if !le.isOpenConnection() {
+		t.Fatal("expected a connection with server data to be open")
+	}
+
+	// the server closes the connection after sending more data
+	conn.data = []byte("bye")
+	conn.Close()
+
+	if le.isOpenConnection() {
+		t.Fatal("expected the closed connection to be detected despite the kept data")
+	}
+}
+
+func TestIsOpenConnectionBoundsServerData(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 3*maxPeekedBytes)
+	conn := &talkingConnection{fakeConnection: &fakeConnection{}, data: data}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	for i := 0; i < 4; i++ {
+		if !le.isOpenConnection() {
+			t.Fatal("expected a connection with server data to be open")
+		}
+	}
+
+	if len(conn.data) != 0 {
+		t.Fatalf("expected the server data to be read, %d bytes left", len(conn.data))
+	}
+
+	if peeked := le.conn.(*peekedConn).peeked; len(peeked) != maxPeekedBytes {
+		t.Fatalf("expected %d kept bytes, got %d", maxPeekedBytes, len(peeked))
+	}
+}
+
+func TestKeepAlivePingsAndStopsOnClose(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+
+	le.SetKeepAlive(time.Millisecond)
+	k := le.keepAlive
+
+	deadline := time.Now().Add(time.Second)
+	for len(conn.Written()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if writes := conn.Written(); len(writes) == 0 || string(writes[0]) != "myToken \n" {
+		t.Fatalf("expected the keepalive to ping, got %q", writes)
+	}
+
+	le.Close()
+
+	select {
+	case <-k.done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the keepalive goroutine to exit")
+	}
+}
+
 func TestOpenConnectionOpensConnection(t *testing.T) {
 	le, err := Connect("")
 	if err != nil {
@@ -132,34 +689,135 @@ func TestSetPrefixSetsPrefix(t *testing.T) {
 	}
 }
 
-func TestLoggerImplementsWriterInterface(t *testing.T) {
-	le, err := Connect("myToken")
-	if err != nil {
-		t.Fatal(err)
-	}
+func TestSetTokenSetsToken(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
 
-	defer le.Close()
+	le.SetToken("myNewToken")
+	le.Print("test")
 
-	// the test will fail to compile if Logger doesn't implement io.Writer
-	func(w io.Writer) {}(le)
+	if writes := conn.Written(); len(writes) != 1 || !strings.HasPrefix(string(writes[0]), "myNewToken ") {
+		t.Fail()
+	}
 }
 
-func TestReplaceNewline(t *testing.T) {
-	le, err := Connect("myToken")
+func TestSetHostDialsNewHost(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer l.Close()
+
+	accepted := make(chan bool, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err == nil
+	}()
 
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
 	defer le.Close()
 
-	le.Println("1\n2\n3")
+	le.SetHost(l.Addr().String())
 
-	if strings.Count(string(le.buf), "\u2028") != 2 {
-		t.Fail()
+	// the listener doesn't speak TLS so the write itself fails
+	le.Write([]byte("test"))
+
+	if !<-accepted {
+		t.Fatal("expected a connection to the new host")
+	}
+
+	if len(conn.Written()) != 0 {
+		t.Fatal("expected no write to the old connection")
 	}
 }
 
-func TestAddNewline(t *testing.T) {
+func TestFailoverHostsUsesReachableHost(t *testing.T) {
+	srv, config := newTLSTestServer()
+	defer srv.Close()
+
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	le.SetTLSConfig(config)
+
+	// nothing listens on port 1
+	le.SetHosts("127.0.0.1:1", srv.Listener.Addr().String())
+
+	if err := le.openConnection(); err != nil {
+		t.Fatal(err)
+	}
+
+	if le.hostIndex != 1 {
+		t.Fatalf("expected the second host to be used, got %d", le.hostIndex)
+	}
+}
+
+func TestSetTCPKeepAliveDials(t *testing.T) {
+	srv, config := newTLSTestServer()
+	defer srv.Close()
+
+	le := ConnectLazy("myToken")
+	defer le.Close()
+
+	le.SetTLSConfig(config)
+	le.SetHost(srv.Listener.Addr().String())
+	le.SetTCPKeepAlive(30 * time.Second)
+
+	if err := le.openConnection(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrOutputDefaultsToStderr(t *testing.T) {
+	le := Logger{token: "myToken"}
+
+	if le.errWriter() != os.Stderr {
+		t.Fatal("expected the error output to default to os.Stderr")
+	}
+
+	le.SetErrOutput(os.Stdout)
+	le.SetErrOutput(nil)
+
+	if le.errWriter() != os.Stderr {
+		t.Fatal("expected a nil error output to restore os.Stderr")
+	}
+}
+
+func TestErrOutputFailureFallsBackToStderr(t *testing.T) {
+	stderr, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+
+	defer func(orig *os.File) { os.Stderr = orig }(os.Stderr)
+	os.Stderr = stderr
+
+	le := Logger{token: "myToken"}
+	le.SetErrOutput(failingWriter{})
+
+	le.errorf("dropped a message: %v", "test")
+
+	b, err := ioutil.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), "le_go: dropped a message: test\n") {
+		t.Fatalf("expected the diagnostic on os.Stderr, got %q", b)
+	}
+
+	if failures := le.Stats().ErrOutputFailures; failures != 1 {
+		t.Fatalf("expected 1 error output failure, got %d", failures)
+	}
+}
+
+func TestLoggerImplementsWriterInterface(t *testing.T) {
 	le, err := Connect("myToken")
 	if err != nil {
 		t.Fatal(err)
@@ -167,19 +825,192 @@ func TestAddNewline(t *testing.T) {
 
 	defer le.Close()
 
-	le.Print("123")
+	// the test will fail to compile if Logger doesn't implement io.Writer
+	func(w io.Writer) {}(le)
+}
+
+func TestReplaceNewline(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+
+	defer le.Close()
+
+	le.Println("1\n2\n3")
 
-	if !strings.HasSuffix(string(le.buf), "\n") {
+	if writes := conn.Written(); len(writes) != 1 || strings.Count(string(writes[0]), "\u2028") != 2 {
 		t.Fail()
 	}
+}
+
+func TestNormalizeNewlines(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{"no newline", "a", "myToken  a\n"},
+		{"trailing newline", "a\n", "myToken  a\n"},
+		{"interior newlines", "a\nb\nc", "myToken  a\u2028b\u2028c\n"},
+		{"interior and trailing newlines", "a\nb\nc\n", "myToken  a\u2028b\u2028c\n"},
+	}
+
+	le := Logger{token: "myToken"}
+
+	for _, test := range tests {
+		if got := string(le.makeBuf(nil, " ", []byte(test.msg))); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestNormalizeCR(t *testing.T) {
+	le := Logger{token: "myToken"}
+
+	if got := string(le.makeBuf(nil, " ", []byte("a\r\nb"))); got != "myToken  a\r\u2028b\n" {
+		t.Fatalf("expected \\r to be kept by default, got %q", got)
+	}
+
+	le.SetNormalizeCR(true)
+
+	if got := string(le.makeBuf(nil, " ", []byte("a\r\nb\r\nc"))); got != "myToken  a\u2028b\u2028c\n" {
+		t.Fatalf("expected a single event, got %q", got)
+	}
+
+	if got := string(le.makeBuf(nil, " ", []byte("a\rb\r\n"))); got != "myToken  a\u2028b\n" {
+		t.Fatalf("expected lone \\r to be replaced, got %q", got)
+	}
+}
+
+func TestPreserveNewlines(t *testing.T) {
+	le := Logger{token: "myToken"}
+	le.SetPreserveNewlines(true)
+
+	if got := string(le.makeBuf(nil, " ", []byte("a\nb\n"))); got != "myToken  a\nb\n" {
+		t.Fatalf("expected the newlines to be kept, got %q", got)
+	}
+}
+
+func TestSetLineSeparator(t *testing.T) {
+	le := Logger{token: "myToken"}
+	le.SetLineSeparator("", " | ")
+
+	if got := string(le.makeBuf(nil, " ", []byte("a\nb\n"))); got != "myToken  a | b\n" {
+		t.Fatalf("expected the custom replacement, got %q", got)
+	}
+
+	le.SetLineSeparator("\r\n", "")
+
+	if got := string(le.makeBuf(nil, " ", []byte("a\r\nb\r\n"))); got != "myToken  a\u2028b\r\n" {
+		t.Fatalf("expected the custom separator, got %q", got)
+	}
+}
+
+func TestAddNewline(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
 
+	defer le.Close()
+
+	le.Print("123")
 	le.Printf("%s", "123")
 
-	if !strings.HasSuffix(string(le.buf), "\n") {
+	writes := conn.Written()
+	if len(writes) != 2 {
+		t.Fatalf("expected 2 writes, got %d", len(writes))
+	}
+
+	for _, w := range writes {
+		if !strings.HasSuffix(string(w), "\n") {
+			t.Fail()
+		}
+	}
+}
+
+func TestWriteRetriesOnFailure(t *testing.T) {
+	conn := &fakeConnection{failWrites: 1}
+	le := Logger{conn: conn, token: "myToken"}
+
+	// the failing connection still reports itself open,
+	// the retry must reconnect regardless
+	redialed := &fakeConnection{}
+	le.SetDialFunc(func() (net.Conn, error) {
+		return redialed, nil
+	})
+
+	if _, err := le.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !conn.closed {
+		t.Fatal("expected the failed connection to be closed")
+	}
+
+	writes := redialed.Written()
+	if len(writes) != 1 {
+		t.Fatalf("expected 1 write, got %d", len(writes))
+	}
+
+	if !bytes.HasPrefix(writes[0], []byte("myToken ")) {
 		t.Fail()
 	}
 }
 
+func TestWriteUsesFallbackOnFailure(t *testing.T) {
+	conn := &fakeConnection{failWrites: 2}
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+
+	// the write and its single retry fail
+	le.SetRetryPolicy(ConstantBackoff{MaxRetries: 1})
+
+	var fallback bytes.Buffer
+	le.SetFallback(&fallback)
+
+	if _, err := le.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+
+	if fallback.String() != "myToken  test\n" {
+		t.Fatalf("unexpected fallback content %q", fallback.String())
+	}
+
+	if stats := le.Stats(); stats.Sent != 0 || stats.Errors != 1 {
+		t.Fatalf("expected a failed write and no sent message, got %+v", stats)
+	}
+}
+
+// newTLSTestServer starts a local TLS server and returns a client
+// config trusting it
+func newTLSTestServer() (*httptest.Server, *tls.Config) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	return srv, &tls.Config{RootCAs: roots}
+}
+
+func TestSplitLongMessage(t *testing.T) {
+	le := Logger{token: "myToken"}
+
+	buf := le.makeBuf(nil, " ", []byte(strings.Repeat("a", maxLogLength+10)))
+
+	lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "myToken ") {
+			t.Fatal("expected every line to start with the token")
+		}
+	}
+
+	stats := le.Stats()
+	if stats.Split != 1 || stats.LastSplitLength != maxLogLength+10 || stats.LastSplitChunks != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
 func ExampleLogger() {
 	le, err := Connect("XXXX-XXXX-XXXX-XXXX") // replace with token
 	if err != nil {
@@ -204,25 +1035,854 @@ func ExampleLogger_write() {
 
 func BenchmarkMakeBuf(b *testing.B) {
 	le := Logger{token: "token"}
+	b.ReportAllocs()
 
+	var buf []byte
 	for i := 0; i < b.N; i++ {
-		le.makeBuf([]byte("test\nstring\n"))
+		buf = le.makeBuf(buf[:0], " ", []byte("test\nstring\n"))
 	}
 }
 
 func BenchmarkMakeBufWithoutNewlineSuffix(b *testing.B) {
 	le := Logger{token: "token"}
+	b.ReportAllocs()
 
+	var buf []byte
 	for i := 0; i < b.N; i++ {
-		le.makeBuf([]byte("test\nstring"))
+		buf = le.makeBuf(buf[:0], " ", []byte("test\nstring"))
 	}
 }
 
 func BenchmarkMakeBufWithPrefix(b *testing.B) {
 	le := Logger{token: "token"}
 	le.SetPrefix("prefix")
+	b.ReportAllocs()
 
+	var buf []byte
 	for i := 0; i < b.N; i++ {
-		le.makeBuf([]byte("test\nstring\n"))
+		buf = le.makeBuf(buf[:0], le.header(SeverityInfo), []byte("test\nstring\n"))
+	}
+}
+
+func BenchmarkMakeBufSplit(b *testing.B) {
+	le := Logger{token: "token"}
+	msg := []byte(strings.Repeat("a", 4*maxLogLength))
+	b.ReportAllocs()
+
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf = le.makeBuf(buf[:0], " ", msg)
+	}
+}
+
+// discardConnection is a fakeConnection which doesn't record the writes
+type discardConnection struct {
+	fakeConnection
+}
+
+func (c *discardConnection) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func BenchmarkWrite(b *testing.B) {
+	le := Logger{conn: &discardConnection{}, token: "token"}
+	msg := []byte("test string")
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		le.Write(msg)
+	}
+}
+
+func BenchmarkOutputCaller(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		flag int
+	}{
+		{"Timestamp", log.LstdFlags},
+		{"TimestampAndCaller", log.LstdFlags | log.Lshortfile},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			le := Logger{conn: &discardConnection{}, token: "token"}
+			le.SetFlags(bb.flag)
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				le.Output(1, "test string")
+			}
+		})
+	}
+}
+
+func BenchmarkWriteAfterLargeMessage(b *testing.B) {
+	le := Logger{conn: &discardConnection{}, token: "token"}
+	le.Write([]byte(strings.Repeat("a", 4*maxLogLength)))
+	msg := []byte("test string")
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		le.Write(msg)
+	}
+}
+
+func TestWriteTCPConnUsesBuffers(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := make(chan []byte)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		defer c.Close()
+
+		b, _ := ioutil.ReadAll(c)
+		received <- b
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	msg := strings.Repeat("a", maxLogLength+10) + "\n"
+
+	if _, err := le.Write([]byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	le.Close()
+
+	want := le.makeBuf(nil, le.header(SeverityInfo), []byte(msg))
+	if got := <-received; !bytes.Equal(got, want) {
+		t.Fatalf("expected %d bytes of split lines, got %d bytes", len(want), len(got))
+	}
+}
+
+func BenchmarkWriteTCPConn(b *testing.B) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		io.Copy(ioutil.Discard, c)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	le := Logger{conn: conn, token: "token"}
+	defer le.Close()
+
+	msg := []byte(strings.Repeat("a", maxLogLength))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		le.Write(msg)
+	}
+}
+
+func TestSetProcessFields(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.SetProcessFields(true, true)
+	le.Print("test")
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "myToken myPrefix hostname=" + hostname + " pid=" + strconv.Itoa(os.Getpid()) + " test\n"
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != want {
+		t.Fatalf("expected %q, got %q", want, writes)
+	}
+}
+
+func TestSetTags(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetTags(map[string]string{
+		"service": "checkout",
+		"env":     "prod",
+		"region":  "us east",
+	})
+	le.Println("test")
+
+	want := "myToken  test env=prod region=\"us east\" service=checkout\n"
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != want {
+		t.Fatalf("expected %q, got %q", want, writes)
+	}
+
+	le.SetTags(nil)
+	le.Print("test")
+
+	if writes := conn.Written(); len(writes) != 2 || string(writes[1]) != "myToken  test\n" {
+		t.Fatalf("expected no tags, got %q", writes)
+	}
+}
+
+func TestSetRedaction(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetRedaction([]*regexp.Regexp{regexp.MustCompile(`\b(?:\d[ -]?){12,15}\d\b`)}, "[REDACTED]")
+	le.Print("paid with 4111 1111 1111 1111 today")
+
+	want := "myToken  paid with [REDACTED] today\n"
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != want {
+		t.Fatalf("expected %q, got %q", want, writes)
+	}
+}
+
+func TestSetRedactionBeforeSplit(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetRedaction([]*regexp.Regexp{regexp.MustCompile(`secret`)}, "******")
+
+	// the secret straddles the chunk boundary
+	le.Print(strings.Repeat("a", maxLogLength-3) + "secret")
+
+	if writes := conn.Written(); len(writes) != 1 || strings.Contains(string(writes[0]), "sec") {
+		t.Fatalf("expected the secret to be redacted before splitting, got %d writes", len(writes))
+	}
+}
+
+func TestSetStripANSI(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	msg := "\x1b[1;31merror\x1b[0m: \x1b[2Kdone"
+
+	le.Print(msg)
+	le.SetStripANSI(true)
+	le.Print(msg)
+	le.WriteString(msg)
+
+	writes := conn.Written()
+	if len(writes) != 3 || !bytes.Contains(writes[0], []byte("\x1b[")) {
+		t.Fatalf("expected the escape codes to be kept by default, got %q", writes)
+	}
+
+	if string(writes[1]) != "myToken  error: done\n" || string(writes[2]) != string(writes[1]) {
+		t.Fatalf("expected the escape codes to be removed, got %q", writes[1:])
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	std := StdLogger(&le, "lib: ", log.Lshortfile)
+	std.Println("test")
+
+	writes := conn.Written()
+	if len(writes) != 1 {
+		t.Fatalf("expected 1 write, got %d", len(writes))
+	}
+
+	if !regexp.MustCompile(`^myToken  lib: le_test\.go:\d+: test\n$`).Match(writes[0]) {
+		t.Fatalf("unexpected line %q", writes[0])
+	}
+}
+
+func TestCallerOfEveryEntryPoint(t *testing.T) {
+	// each log func returns the line of its logging call
+	for _, tt := range []struct {
+		name string
+		log  func(le *Logger) int
+	}{
+		{"Print", func(le *Logger) int {
+			_, _, line, _ := runtime.Caller(0)
+			le.Print("test")
+			return line + 1
+		}},
+		{"Printf", func(le *Logger) int {
+			_, _, line, _ := runtime.Caller(0)
+			le.Printf("%s", "test")
+			return line + 1
+		}},
+		{"Println", func(le *Logger) int {
+			_, _, line, _ := runtime.Caller(0)
+			le.Println("test")
+			return line + 1
+		}},
+		{"Output", func(le *Logger) int {
+			_, _, line, _ := runtime.Caller(0)
+			le.Output(1, "test")
+			return line + 1
+		}},
+		{"OutputSeverity", func(le *Logger) int {
+			_, _, line, _ := runtime.Caller(0)
+			le.OutputSeverity(1, SeverityError, "test")
+			return line + 1
+		}},
+		{"PrintContext", func(le *Logger) int {
+			_, _, line, _ := runtime.Caller(0)
+			le.PrintContext(context.Background(), "test")
+			return line + 1
+		}},
+		{"Fatal", func(le *Logger) int {
+			_, _, line, _ := runtime.Caller(0)
+			le.Fatal("test")
+			return line + 1
+		}},
+		{"Panic", func(le *Logger) (line int) {
+			defer func() { recover() }()
+			_, _, line, _ = runtime.Caller(0)
+			line += 2
+			le.Panic("test")
+			return line
+		}},
+		{"StdLogger", func(le *Logger) int {
+			_, _, line, _ := runtime.Caller(0)
+			StdLogger(le, "", log.Lshortfile).Print("test")
+			return line + 1
+		}},
+	} {
+		conn := &fakeConnection{}
+		le := Logger{conn: conn, token: "myToken"}
+		le.SetFlags(log.Lshortfile)
+		le.SetExitFunc(func(int) {}, nil)
+
+		line := tt.log(&le)
+		le.Close()
+
+		want := fmt.Sprintf("myToken  le_test.go:%d: test\n", line)
+		if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != want {
+			t.Errorf("%s: expected %q, got %q", tt.name, want, writes)
+		}
+	}
+}
+
+func TestSetConn(t *testing.T) {
+	old := &fakeConnection{}
+	le := Logger{conn: old, token: "myToken"}
+	defer le.Close()
+
+	le.Print("1")
+
+	conn := &fakeConnection{}
+	le.SetConn(conn, true)
+	le.Print("2")
+
+	if len(old.Written()) != 1 || !old.closed {
+		t.Fatal("expected the old connection to be closed after 1 write")
+	}
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken  2\n" {
+		t.Fatalf("expected the write to go to the new connection, got %q", writes)
+	}
+
+	le.SetConn(&fakeConnection{}, false)
+
+	if conn.closed {
+		t.Fatal("expected the old connection to be kept open")
+	}
+}
+
+// failingWriter is an io.Writer which always fails
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestSetTee(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	var tee bytes.Buffer
+	le.SetTee(&tee)
+
+	le.Print("1")
+	le.Print("2")
+
+	var written []byte
+	for _, w := range conn.Written() {
+		written = append(written, w...)
+	}
+
+	if tee.String() != string(written) {
+		t.Fatalf("expected the tee to receive %q, got %q", written, tee.String())
+	}
+}
+
+func TestSetTeeErrorDoesntFailWrite(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	var errOutput bytes.Buffer
+	le.SetErrOutput(&errOutput)
+	le.SetTee(failingWriter{})
+
+	if err := le.Print("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conn.Written()) != 1 {
+		t.Fatal("expected the line to be written to the connection")
+	}
+
+	if !strings.Contains(errOutput.String(), "disk full") {
+		t.Fatalf("expected the tee error to be reported, got %q", errOutput.String())
+	}
+}
+
+// panicConnection is a fakeConnection whose writes panic
+type panicConnection struct {
+	fakeConnection
+}
+
+func (c *panicConnection) Write(b []byte) (int, error) {
+	panic("boom")
+}
+
+func TestWritePanicIsRecovered(t *testing.T) {
+	le := Logger{conn: &panicConnection{}, token: "myToken", fixedConn: true}
+	defer le.Close()
+
+	var errOutput bytes.Buffer
+	le.SetErrOutput(&errOutput)
+
+	if err := le.Print("test"); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+
+	if !strings.Contains(errOutput.String(), "boom") {
+		t.Fatalf("expected the panic to be reported, got %q", errOutput.String())
+	}
+}
+
+func TestSetRethrowPanics(t *testing.T) {
+	le := Logger{conn: &panicConnection{}, token: "myToken", fixedConn: true}
+	defer le.Close()
+
+	le.SetErrOutput(ioutil.Discard)
+	le.SetRethrowPanics(true)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the panic to be rethrown, got %v", r)
+			}
+		}()
+
+		le.Print("test")
+	}()
+
+	// the logger is still usable after the panic
+	conn := &fakeConnection{}
+	le.SetConn(conn, false)
+
+	if err := le.Print("test"); err != nil || len(conn.Written()) != 1 {
+		t.Fatalf("expected the logger to keep working, got %v", err)
+	}
+}
+
+func TestSetExitFunc(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	var calls []string
+	le.SetExitFunc(func(code int) {
+		calls = append(calls, "exit "+strconv.Itoa(code))
+	}, func() {
+		calls = append(calls, "before exit")
+	})
+
+	le.Fatal("fatal")
+
+	if strings.Join(calls, ", ") != "before exit, exit 1" {
+		t.Fatalf("unexpected calls %q", calls)
+	}
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken  fatal\n" {
+		t.Fatalf("expected the fatal message to be written, got %q", writes)
+	}
+}
+
+func TestFatalBypassesConcurrentWritesLimit(t *testing.T) {
+	conn := &fakeConnection{delay: 20 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	exited := false
+	le.SetExitFunc(func(code int) { exited = true }, nil)
+	le.SetHeaderSeverity(SeverityBeforeTimestamp, false)
+
+	// the only write goroutine is busy
+	le.SetConcurrentWrites(1)
+	le.Print("1")
+
+	le.Fatal("fatal")
+
+	if !exited {
+		t.Fatal("expected Fatal to exit")
+	}
+
+	if !written(conn, "myToken  CRITICAL fatal\n") {
+		t.Fatalf("expected the fatal message to be written, got %q", conn.Written())
+	}
+
+	// the rate limit doesn't drop the message before a panic either
+	le.SetRateLimit(1, 1)
+	le.Print("2")
+
+	func() {
+		defer func() { recover() }()
+		le.Panic("panic")
+	}()
+
+	if !written(conn, "myToken  CRITICAL panic\n") {
+		t.Fatalf("expected the panic message to be written, got %q", conn.Written())
+	}
+}
+
+// written returns if line was written to conn
+func written(conn *fakeConnection, line string) bool {
+	for _, w := range conn.Written() {
+		if string(w) == line {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestWriteString(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.Write([]byte("1\n2\n"))
+	le.WriteString("1\n2\n")
+
+	writes := conn.Written()
+	if len(writes) != 2 || string(writes[0]) != string(writes[1]) {
+		t.Fatalf("expected WriteString to write same as Write, got %q", writes)
+	}
+}
+
+func TestWriteCountsMessageBytes(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	if n, err := io.Copy(&le, strings.NewReader("hello")); err != nil || n != 5 {
+		t.Fatalf("expected io.Copy to write 5 bytes, got %d, %v", n, err)
+	}
+
+	if n, err := le.Write([]byte("abc")); err != nil || n != 3 {
+		t.Fatalf("expected Write to return 3, got %d, %v", n, err)
+	}
+
+	if n, err := le.WriteRaw([]byte("abc")); err != nil || n != 3 {
+		t.Fatalf("expected WriteRaw to return 3, got %d, %v", n, err)
+	}
+
+	if writes := conn.Written(); len(writes) != 3 || string(writes[0]) != "myToken myPrefix hello\n" {
+		t.Fatalf("expected 3 writes, got %q", writes)
+	}
+}
+
+func TestWriteLines(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	if err := le.WriteLines([]string{"1", "2\n", "3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := conn.Written()
+	if len(writes) != 1 || string(writes[0]) != "myToken myPrefix 1\nmyToken myPrefix 2\nmyToken myPrefix 3\n" {
+		t.Fatalf("expected the lines in order in a single write, got %q", writes)
+	}
+
+	if sent := le.Stats().Sent; sent != 3 {
+		t.Fatalf("expected 3 sent messages, got %d", sent)
+	}
+}
+
+func TestWriteLinesReturnsErrors(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetMaxMessageSize(3, DropOversized)
+	if err := le.WriteLines([]string{"1", "too long", "3"}); err != errMessageTooLarge {
+		t.Fatalf("expected errMessageTooLarge, got %v", err)
+	}
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken  1\nmyToken  3\n" {
+		t.Fatalf("expected the other lines to be written, got %q", writes)
+	}
+
+	le.SetRetryPolicy(NoRetry)
+	conn.failWrites = 1
+	if err := le.WriteLines([]string{"4"}); err == nil {
+		t.Fatal("expected the write error")
+	}
+}
+
+func TestWriteRaw(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.SetFlags(log.LstdFlags | log.Lshortfile)
+	le.SetTags(map[string]string{"env": "prod"})
+
+	le.WriteRaw([]byte(`{"msg":"test"}`))
+	le.WriteRaw([]byte(`{"msg":"test"}` + "\n"))
+
+	writes := conn.Written()
+	if len(writes) != 2 || string(writes[0]) != "myToken {\"msg\":\"test\"}\n" || string(writes[1]) != string(writes[0]) {
+		t.Fatalf("expected the raw lines after the token, got %q", writes)
+	}
+}
+
+func BenchmarkWriteString(b *testing.B) {
+	le := Logger{conn: &discardConnection{}, token: "token"}
+	msg := strings.Repeat("a", 1024)
+	b.ReportAllocs()
+
+	b.Run("Write", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			le.Write([]byte(msg))
+		}
+	})
+
+	b.Run("WriteString", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			le.WriteString(msg)
+		}
+	})
+}
+
+func TestWriteAddsTokenAndPrefix(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	fmt.Fprintln(&le, "1\n2")
+
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != "myToken myPrefix 1\u20282\n" {
+		t.Fatalf("expected the line to start with the token and prefix, got %q", writes)
+	}
+}
+
+func TestSetStackTraces(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetStackTraces(true)
+
+	le.OutputSeverity(1, SeverityError, "failed")
+	le.Print("done")
+
+	writes := conn.Written()
+	if len(writes) != 2 {
+		t.Fatalf("expected 2 writes, got %d", len(writes))
+	}
+
+	// the trace starts at the caller
+	if !strings.HasPrefix(string(writes[0]), "myToken  failed\u2028github.com/bsphere/le_go.TestSetStackTraces\u2028\t") {
+		t.Fatalf("expected a stack trace starting at the caller, got %q", writes[0])
+	}
+
+	if string(writes[1]) != "myToken  done\n" {
+		t.Fatalf("expected no stack trace for SeverityInfo, got %q", writes[1])
+	}
+}
+
+func TestSetGoroutineID(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.SetGoroutineID(true)
+	le.Print("test")
+
+	writes := conn.Written()
+	if len(writes) != 1 || !regexp.MustCompile(`^myToken myPrefix goroutine=[1-9]\d* test\n$`).Match(writes[0]) {
+		t.Fatalf("expected a goroutine ID, got %q", writes)
+	}
+}
+
+func TestWithUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "le_go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := net.Listen("unix", filepath.Join(dir, "relay.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	le, err := Connect("myToken", WithUnixSocket(filepath.Join(dir, "relay.sock")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer le.Close()
+
+	le.SetPrefix("myPrefix")
+	le.Print("test")
+
+	if line := <-received; line != "myToken myPrefix test\n" {
+		t.Fatalf("expected the line on the socket, got %q", line)
+	}
+}
+
+func TestStatsCountsSentAndReconnects(t *testing.T) {
+	conn := &fakeConnection{failWrites: 1}
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+	defer le.Close()
+
+	le.Print("1")
+	le.Print("2")
+
+	if stats := le.Stats(); stats.Sent != 2 || stats.Reconnects != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRetryKeepsTimestamp(t *testing.T) {
+	// the write fails, the message is written on a new connection
+	// after the backoff
+	conn := &fakeConnection{failWrites: 1}
+	clock := newFakeClock()
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial(), clock: clock}
+	defer le.Close()
+
+	le.SetTimestampFormat(time.RFC3339)
+
+	done := make(chan error, 1)
+	go func() { done <- le.Print("test") }()
+
+	// the clock moves once the message is logged and its writes failed
+	deadline := time.Now().Add(time.Second)
+	for conn.failing() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	for len(conn.Written()) == 0 && time.Now().Before(deadline) {
+		clock.Advance(time.Second)
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	writes := conn.Written()
+	if len(writes) != 1 || string(writes[0]) != "myToken  2020-01-02T03:04:05Z test\n" {
+		t.Fatalf("expected the timestamp of the logged message, got %q", writes)
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	conn := &fakeConnection{failWrites: 1}
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+	defer le.Close()
+
+	reconnected := make(chan struct{}, 1)
+	le.SetOnReconnect(func() {
+		// the callback may log
+		le.Print("reconnected")
+		reconnected <- struct{}{}
+	})
+
+	var errs []error
+	le.SetOnError(func(err error) {
+		errs = append(errs, err)
+	})
+
+	le.Print("1")
+
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("expected OnReconnect to be called")
+	}
+
+	le.SetDialFunc(func() (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	})
+	conn.Close()
+
+	if err := le.Print("2"); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+
+	if len(errs) == 0 {
+		t.Fatal("expected OnError to be called")
+	}
+}
+
+func TestNewNop(t *testing.T) {
+	le := NewNop()
+
+	le.Print("test")
+	le.Printf("%s", "test")
+	le.OutputSeverity(1, SeverityError, "test")
+
+	if n, err := le.Write([]byte("test")); n != 4 || err != nil {
+		t.Fatalf("expected the write to be discarded, got %d, %v", n, err)
+	}
+
+	le.Flush()
+
+	if le.conn != nil {
+		t.Fatal("expected no connection")
+	}
+
+	if err := le.Close(); err != nil {
+		t.Fatal(err)
 	}
 }
@@ -0,0 +1,10 @@
+module github.com/bsphere/le_go/leotel
+
+go 1.12
+
+require (
+	github.com/bsphere/le_go v0.0.0
+	go.opentelemetry.io/otel/trace v1.7.0
+)
+
+replace github.com/bsphere/le_go => ../
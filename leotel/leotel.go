@@ -0,0 +1,22 @@
+// Package leotel correlates the logs of a le_go Logger with OpenTelemetry
+// traces, it is a separate module so le_go itself doesn't depend on
+// OpenTelemetry.
+package leotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDs returns the trace and span IDs of the span of ctx, or empty IDs
+// if ctx has no valid span context. It is meant to be set with
+// le_go.Logger.SetTraceExtractor(leotel.TraceIDs).
+func TraceIDs(ctx context.Context) (traceID, spanID string) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+
+	return sc.TraceID().String(), sc.SpanID().String()
+}
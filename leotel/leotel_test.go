@@ -0,0 +1,44 @@
+package leotel
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/bsphere/le_go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceIDs(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	le := le_go.NewWithConn(client, "myToken")
+	defer le.Close()
+
+	le.SetTraceExtractor(TraceIDs)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	go le.PrintContext(ctx, "test")
+
+	line, err := bufio.NewReader(server).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "myToken  test trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7\n"; line != want {
+		t.Fatalf("expected %q, got %q", want, line)
+	}
+
+	if traceID, spanID := TraceIDs(context.Background()); traceID != "" || spanID != "" {
+		t.Fatalf("expected no IDs without a span, got %q %q", traceID, spanID)
+	}
+}
@@ -0,0 +1,60 @@
+// Package leprometheus exports the counters of a le_go Logger
+// as Prometheus metrics, it is a separate module so le_go itself
+// doesn't depend on the Prometheus client.
+package leprometheus
+
+import (
+	"github.com/bsphere/le_go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	sentDesc = prometheus.NewDesc("le_go_sent_total",
+		"Number of messages written to the connection.", nil, nil)
+	errorsDesc = prometheus.NewDesc("le_go_errors_total",
+		"Number of failed writes, including the ones spooled, queued or written to the fallback.", nil, nil)
+	droppedDesc = prometheus.NewDesc("le_go_dropped_total",
+		"Number of lines discarded by the logger.", nil, nil)
+	splitDesc = prometheus.NewDesc("le_go_split_total",
+		"Number of messages split into multiple lines.", nil, nil)
+	reconnectsDesc = prometheus.NewDesc("le_go_reconnects_total",
+		"Number of connections opened to replace a previous one.", nil, nil)
+	queueDepthDesc = prometheus.NewDesc("le_go_queue_depth",
+		"Number of lines waiting in the retry queue.", nil, nil)
+	inFlightDesc = prometheus.NewDesc("le_go_in_flight",
+		"Number of goroutines writing messages.", nil, nil)
+)
+
+// collector reads the metrics from the Stats of a Logger on every scrape
+type collector struct {
+	logger *le_go.Logger
+}
+
+// Collector returns a Prometheus collector of the counters of l,
+// see le_go.Logger.Stats(). It can be registered with any registry,
+// e.g. prometheus.MustRegister(leprometheus.Collector(l)).
+func Collector(l *le_go.Logger) prometheus.Collector {
+	return &collector{logger: l}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sentDesc
+	ch <- errorsDesc
+	ch <- droppedDesc
+	ch <- splitDesc
+	ch <- reconnectsDesc
+	ch <- queueDepthDesc
+	ch <- inFlightDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.logger.Stats()
+
+	ch <- prometheus.MustNewConstMetric(sentDesc, prometheus.CounterValue, float64(stats.Sent))
+	ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, float64(stats.Errors))
+	ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(stats.Dropped))
+	ch <- prometheus.MustNewConstMetric(splitDesc, prometheus.CounterValue, float64(stats.Split))
+	ch <- prometheus.MustNewConstMetric(reconnectsDesc, prometheus.CounterValue, float64(stats.Reconnects))
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(stats.QueueDepth))
+	ch <- prometheus.MustNewConstMetric(inFlightDesc, prometheus.GaugeValue, float64(c.logger.InFlight()))
+}
@@ -0,0 +1,64 @@
+package leprometheus
+
+import (
+	"net"
+	"testing"
+
+	"github.com/bsphere/le_go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := server.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	le := le_go.NewWithConn(client, "myToken")
+	defer le.Close()
+
+	le.Print("1")
+	le.Print("2")
+
+	// the write to the closed pipe fails
+	server.Close()
+	le.Print("3")
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(Collector(le))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		metric := family.GetMetric()[0]
+
+		if counter := metric.GetCounter(); counter != nil {
+			values[family.GetName()] = counter.GetValue()
+		} else {
+			values[family.GetName()] = metric.GetGauge().GetValue()
+		}
+	}
+
+	if len(values) != 7 {
+		t.Fatalf("expected 7 metrics, got %v", values)
+	}
+
+	if values["le_go_sent_total"] != 2 {
+		t.Fatalf("expected 2 sent messages, got %v", values["le_go_sent_total"])
+	}
+
+	if values["le_go_errors_total"] == 0 {
+		t.Fatalf("expected the failed write to be counted, got %v", values)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/bsphere/le_go/leprometheus
+
+go 1.12
+
+require (
+	github.com/bsphere/le_go v0.0.0
+	github.com/prometheus/client_golang v1.9.0
+)
+
+replace github.com/bsphere/le_go => ../
@@ -0,0 +1,113 @@
+package le_go
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MultiLogger writes every message to several Loggers, e.g. to two
+// Logentries logs or hosts for redundancy. The Loggers are configured
+// individually and write one after the other, in the given order.
+type MultiLogger struct {
+	loggers []*Logger
+}
+
+// MultiError holds the errors of the Loggers of a MultiLogger which failed
+// to write a message, the message was written by the other ones
+type MultiError []error
+
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return "le_go: " + strconv.Itoa(len(e)) + " loggers failed: " + strings.Join(msgs, "; ")
+}
+
+// err returns e as an error, nil if it is empty
+func (e MultiError) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// NewMultiLogger creates a MultiLogger which writes the messages with
+// all of loggers
+func NewMultiLogger(loggers ...*Logger) *MultiLogger {
+	return &MultiLogger{loggers: loggers}
+}
+
+// Close closes all the Loggers, it returns a MultiError if any failed
+func (m *MultiLogger) Close() error {
+	var errs MultiError
+	for _, logger := range m.loggers {
+		if err := logger.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs.err()
+}
+
+// Flush flushes all the Loggers, see Logger.Flush()
+func (m *MultiLogger) Flush() {
+	for _, logger := range m.loggers {
+		logger.Flush()
+	}
+}
+
+// Output is same as Logger.Output() but writes s with all the Loggers,
+// it returns a MultiError if any failed
+func (m *MultiLogger) Output(calldepth int, s string) error {
+	var errs MultiError
+	for _, logger := range m.loggers {
+		if err := logger.Output(calldepth+1, s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs.err()
+}
+
+// Print is same as Logger.Print() but writes with all the Loggers
+func (m *MultiLogger) Print(v ...interface{}) error {
+	return m.Output(2, fmt.Sprint(v...))
+}
+
+// Printf is same as Logger.Printf() but writes with all the Loggers
+func (m *MultiLogger) Printf(format string, v ...interface{}) error {
+	return m.Output(2, fmt.Sprintf(format, v...))
+}
+
+// Println is same as Logger.Println() but writes with all the Loggers
+func (m *MultiLogger) Println(v ...interface{}) error {
+	return m.Output(2, fmt.Sprintln(v...))
+}
+
+// Write is same as Logger.Write() but writes with all the Loggers,
+// it returns a MultiError and the fewest bytes written if any failed
+func (m *MultiLogger) Write(b []byte) (int, error) {
+	var errs MultiError
+
+	written := len(b)
+	for _, logger := range m.loggers {
+		n, err := logger.Write(b)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if n < written {
+			written = n
+		}
+	}
+
+	if err := errs.err(); err != nil {
+		return written, err
+	}
+
+	return len(b), nil
+}
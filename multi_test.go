@@ -0,0 +1,62 @@
+package le_go
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"testing"
+)
+
+func TestMultiLoggerWritesToAll(t *testing.T) {
+	first, second := &fakeConnection{}, &fakeConnection{}
+
+	m := NewMultiLogger(NewWithConn(first, "firstToken"), NewWithConn(second, "secondToken"))
+	defer m.Close()
+
+	if err := m.Print("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Fprintln(m, "written")
+
+	if writes := first.Written(); len(writes) != 2 || string(writes[0]) != "firstToken  test\n" || string(writes[1]) != "firstToken  written\n" {
+		t.Fatalf("unexpected writes to the first log %q", writes)
+	}
+
+	if writes := second.Written(); len(writes) != 2 || string(writes[0]) != "secondToken  test\n" || string(writes[1]) != "secondToken  written\n" {
+		t.Fatalf("unexpected writes to the second log %q", writes)
+	}
+}
+
+func TestMultiLoggerAggregatesErrors(t *testing.T) {
+	broken, conn := &fakeConnection{}, &fakeConnection{}
+	broken.Close()
+
+	m := NewMultiLogger(NewWithConn(broken, "myToken"), NewWithConn(conn, "myToken"))
+	defer m.Close()
+
+	err, ok := m.Print("test").(MultiError)
+	if !ok || len(err) != 1 {
+		t.Fatalf("expected a MultiError with 1 error, got %v", err)
+	}
+
+	if len(conn.Written()) != 1 {
+		t.Fatal("expected the message to be written by the other logger")
+	}
+}
+
+func TestMultiLoggerOutputCaller(t *testing.T) {
+	conn := &fakeConnection{}
+	le := NewWithConn(conn, "myToken")
+	le.SetFlags(log.Lshortfile)
+
+	m := NewMultiLogger(le)
+	defer m.Close()
+
+	m.Print("test")
+
+	writes := conn.Written()
+	if len(writes) != 1 || !regexp.MustCompile(`^myToken  multi_test\.go:\d+: test\n$`).Match(writes[0]) {
+		t.Fatalf("expected the caller of Print, got %q", writes)
+	}
+}
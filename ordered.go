@@ -0,0 +1,164 @@
+package le_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the number of messages which can be pending in ordered mode
+// before Output blocks
+const orderedQueueSize = 1024
+
+var errOrderedStopped = errors.New("le_go: ordered writer is stopped")
+
+// FlushTimeoutError is returned when pending messages weren't written
+// before a timeout expired
+type FlushTimeoutError struct {
+	// Pending is the number of messages which are still pending
+	Pending int
+}
+
+func (e *FlushTimeoutError) Error() string {
+	return fmt.Sprintf("le_go: timed out with %d pending messages", e.Pending)
+}
+
+// orderedWriter writes messages from a single goroutine,
+// in the order they were submitted
+type orderedWriter struct {
+	messages chan orderedMessage
+	stop     chan struct{}
+
+	// pending counts the submitted messages which weren't written yet,
+	// drained is signaled whenever it drops to zero.
+	// unlike a sync.WaitGroup it is safe to wait while messages are submitted
+	mu      sync.Mutex
+	drained *sync.Cond
+	pending int
+
+	// stopped is set under mu once the writer stops accepting messages
+	stopped bool
+}
+
+// orderedMessage is a submitted message with its severity
+// and the header of its lines
+type orderedMessage struct {
+	severity Severity
+	header   string
+	s        string
+}
+
+func newOrderedWriter() *orderedWriter {
+	o := &orderedWriter{
+		messages: make(chan orderedMessage, orderedQueueSize),
+		stop:     make(chan struct{}),
+	}
+	o.drained = sync.NewCond(&o.mu)
+
+	return o
+}
+
+// push submits a message, it blocks while the queue is full until ctx is done.
+// It returns errOrderedStopped if the writer was stopped.
+func (o *orderedWriter) push(ctx context.Context, severity Severity, header, s string) error {
+	o.mu.Lock()
+	if o.stopped {
+		o.mu.Unlock()
+		return errOrderedStopped
+	}
+	o.pending++
+	o.mu.Unlock()
+
+	select {
+	case o.messages <- orderedMessage{severity: severity, header: header, s: s}:
+		return nil
+	case <-o.stop:
+		o.done()
+		return errOrderedStopped
+	case <-ctx.Done():
+		o.done()
+		return ctx.Err()
+	}
+}
+
+// shutdown stops accepting messages, the messages which were already
+// submitted are still written until stop is closed
+func (o *orderedWriter) shutdown() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.stopped = true
+}
+
+// done marks a submitted message as handled
+func (o *orderedWriter) done() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.pending--; o.pending == 0 {
+		o.drained.Broadcast()
+	}
+}
+
+// wait blocks until there are no pending messages
+func (o *orderedWriter) wait() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for o.pending > 0 {
+		o.drained.Wait()
+	}
+}
+
+// waitTimeout blocks until there are no pending messages or until d elapses,
+// it returns the number of messages which are still pending
+func (o *orderedWriter) waitTimeout(d time.Duration) int {
+	expired := false
+
+	timer := time.AfterFunc(d, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		expired = true
+		o.drained.Broadcast()
+	})
+	defer timer.Stop()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for o.pending > 0 && !expired {
+		o.drained.Wait()
+	}
+
+	return o.pending
+}
+
+// runOrderedWriter writes the submitted messages until the writer is stopped,
+// messages which fail to be written or are still pending when it stops
+// are dropped
+func (logger *Logger) runOrderedWriter(o *orderedWriter) {
+	for {
+		select {
+		case m := <-o.messages:
+			if err := logger.output(context.Background(), m.severity, m.header, m.s); err != nil && err != errMessageTooLarge {
+				atomic.AddUint64(&logger.dropped, 1)
+				logger.errorf("dropped a message: %v", err)
+			}
+			o.done()
+		case <-o.stop:
+			for {
+				select {
+				case <-o.messages:
+					atomic.AddUint64(&logger.dropped, 1)
+					o.done()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
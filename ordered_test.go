@@ -0,0 +1,183 @@
+package le_go
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderedModeKeepsSubmissionOrder(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetOrdered(true)
+
+	const n = 100
+
+	var (
+		wg    sync.WaitGroup
+		seqMu sync.Mutex
+		seq   int
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// seqMu defines the submission order
+			seqMu.Lock()
+			le.Print(seq)
+			seq++
+			seqMu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	le.Flush()
+
+	writes := conn.Written()
+	if len(writes) != n {
+		t.Fatalf("expected %d writes, got %d", n, len(writes))
+	}
+
+	for i, w := range writes {
+		if want := "myToken  " + strconv.Itoa(i) + "\n"; string(w) != want {
+			t.Fatalf("write %d: expected %q, got %q", i, want, w)
+		}
+	}
+}
+
+func TestFlushConcurrentWithOutput(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetOrdered(true)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			le.Print(i)
+		}(i)
+		go func() {
+			defer wg.Done()
+			le.Flush()
+		}()
+	}
+
+	wg.Wait()
+	le.Flush()
+
+	if len(conn.Written()) != 50 {
+		t.Fatalf("expected 50 writes, got %d", len(conn.Written()))
+	}
+}
+
+func TestFlushTimeoutReturnsPendingMessages(t *testing.T) {
+	conn := &fakeConnection{delay: 200 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetOrdered(true)
+	le.Print("slow")
+
+	err := le.FlushTimeout(10 * time.Millisecond)
+
+	timeoutErr, ok := err.(*FlushTimeoutError)
+	if !ok {
+		t.Fatalf("expected a *FlushTimeoutError, got %v", err)
+	}
+
+	if timeoutErr.Pending != 1 {
+		t.Fatalf("expected 1 pending message, got %d", timeoutErr.Pending)
+	}
+
+	if err := le.FlushTimeout(time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrderedPushAfterShutdownFails(t *testing.T) {
+	o := newOrderedWriter()
+	o.shutdown()
+
+	for i := 0; i < 100; i++ {
+		if err := o.push(context.Background(), SeverityInfo, " ", "test"); err != errOrderedStopped {
+			t.Fatalf("expected errOrderedStopped, got %v", err)
+		}
+	}
+
+	if len(o.messages) != 0 || o.pending != 0 {
+		t.Fatal("expected no message to be queued")
+	}
+}
+
+func TestOrderedFailedWritesAreDropped(t *testing.T) {
+	client, server := net.Pipe()
+	server.Close()
+
+	le := NewWithConn(client, "myToken")
+	defer le.Close()
+
+	le.SetOrdered(true)
+	le.Print("test")
+	le.Flush()
+
+	if le.Stats().Dropped != 1 {
+		t.Fatalf("expected 1 dropped message, got %d", le.Stats().Dropped)
+	}
+}
+
+func TestSetOrderedAfterClose(t *testing.T) {
+	le := Logger{conn: &fakeConnection{}, token: "myToken"}
+	le.Close()
+
+	le.SetOrdered(true)
+
+	if le.ordered != nil {
+		t.Fatal("expected ordered mode to stay disabled")
+	}
+}
+
+func TestSetErrOutput(t *testing.T) {
+	conn := &fakeConnection{}
+	conn.Close()
+	le := Logger{conn: conn, token: "myToken", fixedConn: true}
+	defer le.Close()
+
+	le.SetOrdered(true)
+
+	var first, second bytes.Buffer
+
+	le.SetErrOutput(&first)
+	le.Print("1")
+	le.Flush()
+
+	if !strings.Contains(first.String(), "dropped a message") {
+		t.Fatalf("expected the dropped message to be reported, got %q", first.String())
+	}
+
+	reported := first.Len()
+
+	le.SetErrOutput(&second)
+	le.Print("2")
+	le.Flush()
+
+	if second.Len() == 0 {
+		t.Fatal("expected the error to be reported to the new writer")
+	}
+
+	if first.Len() != reported {
+		t.Fatal("expected no more errors to be reported to the old writer")
+	}
+}
@@ -0,0 +1,107 @@
+package le_go
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var errEmptyPool = errors.New("le_go: a pool needs at least one connection")
+
+// Pool writes messages over several connections to logentries.com in a
+// round-robin order, each connection is held by its own Logger so the
+// writes on different connections don't wait for each other and every
+// Logger reconnects on its own.
+// The Loggers are configured individually, e.g. with the same prefix.
+type Pool struct {
+	loggers []*Logger
+
+	// accessed atomically, the index of the next Logger
+	next uint64
+}
+
+// ConnectPool is same as Connect() but opens n connections,
+// the messages are written over them in a round-robin order
+func ConnectPool(token string, n int, options ...Option) (*Pool, error) {
+	if n < 1 {
+		return nil, errEmptyPool
+	}
+
+	loggers := make([]*Logger, 0, n)
+	for i := 0; i < n; i++ {
+		logger, err := Connect(token, options...)
+		if err != nil {
+			for _, logger := range loggers {
+				logger.Close()
+			}
+
+			return nil, err
+		}
+
+		loggers = append(loggers, logger)
+	}
+
+	return &Pool{loggers: loggers}, nil
+}
+
+// NewPool creates a Pool which writes the messages with loggers,
+// in a round-robin order. It panics if loggers is empty.
+func NewPool(loggers ...*Logger) *Pool {
+	if len(loggers) == 0 {
+		panic(errEmptyPool)
+	}
+
+	return &Pool{loggers: loggers}
+}
+
+// logger returns the Logger writing the next message
+func (p *Pool) logger() *Logger {
+	i := atomic.AddUint64(&p.next, 1) - 1
+
+	return p.loggers[i%uint64(len(p.loggers))]
+}
+
+// Close closes all the connections of the pool,
+// it returns the first error
+func (p *Pool) Close() error {
+	var err error
+	for _, logger := range p.loggers {
+		if closeErr := logger.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// Flush flushes all the connections of the pool, see Logger.Flush()
+func (p *Pool) Flush() {
+	for _, logger := range p.loggers {
+		logger.Flush()
+	}
+}
+
+// Output is same as Logger.Output() but writes s over the next connection
+func (p *Pool) Output(calldepth int, s string) error {
+	return p.logger().Output(calldepth+1, s)
+}
+
+// Print is same as Logger.Print() but writes over the next connection
+func (p *Pool) Print(v ...interface{}) error {
+	return p.Output(2, fmt.Sprint(v...))
+}
+
+// Printf is same as Logger.Printf() but writes over the next connection
+func (p *Pool) Printf(format string, v ...interface{}) error {
+	return p.Output(2, fmt.Sprintf(format, v...))
+}
+
+// Println is same as Logger.Println() but writes over the next connection
+func (p *Pool) Println(v ...interface{}) error {
+	return p.Output(2, fmt.Sprintln(v...))
+}
+
+// Write is same as Logger.Write() but writes over the next connection
+func (p *Pool) Write(b []byte) (int, error) {
+	return p.logger().Write(b)
+}
@@ -0,0 +1,47 @@
+package le_go
+
+import (
+	"log"
+	"regexp"
+	"testing"
+)
+
+func TestPoolRoundRobin(t *testing.T) {
+	conns := []*fakeConnection{{}, {}, {}}
+
+	var loggers []*Logger
+	for _, conn := range conns {
+		loggers = append(loggers, NewWithConn(conn, "myToken"))
+	}
+
+	p := NewPool(loggers...)
+	defer p.Close()
+
+	for i := 0; i < 6; i++ {
+		if err := p.Print("test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, conn := range conns {
+		if writes := conn.Written(); len(writes) != 2 {
+			t.Errorf("expected 2 writes on connection %d, got %d", i, len(writes))
+		}
+	}
+}
+
+func TestPoolOutputCaller(t *testing.T) {
+	conn := &fakeConnection{}
+	le := NewWithConn(conn, "myToken")
+	le.SetFlags(log.Lshortfile)
+
+	p := NewPool(le)
+	defer p.Close()
+
+	p.Print("test")
+
+	writes := conn.Written()
+	if len(writes) != 1 || !regexp.MustCompile(`^myToken  pool_test\.go:\d+: test\n$`).Match(writes[0]) {
+		t.Fatalf("expected the caller of Print, got %q", writes)
+	}
+}
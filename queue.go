@@ -0,0 +1,110 @@
+package le_go
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errQueueFull    = errors.New("le_go: retry queue is full")
+	errQueueStopped = errors.New("le_go: retry queue is stopped")
+)
+
+// retryQueue is a bounded in-memory queue of lines whose write failed,
+// the lines are retried by a worker goroutine
+type retryQueue struct {
+	lines  chan []byte
+	policy OverflowPolicy
+	stop   chan struct{}
+
+	// done is closed when the worker exits,
+	// held is the line the worker was retrying when it was stopped
+	done chan struct{}
+	held []byte
+}
+
+func newRetryQueue(capacity int, policy OverflowPolicy) *retryQueue {
+	return &retryQueue{
+		lines:  make(chan []byte, capacity),
+		policy: policy,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// push adds a line to the queue, applying the overflow policy if the
+// queue is full. It returns the number of lines which were dropped,
+// or errQueueStopped if the queue was stopped while blocking.
+func (q *retryQueue) push(line []byte) (int, error) {
+	switch q.policy {
+	case Block:
+		select {
+		case q.lines <- line:
+			return 0, nil
+		case <-q.stop:
+			return 0, errQueueStopped
+		}
+	case DropOldest:
+		dropped := 0
+		for {
+			select {
+			case q.lines <- line:
+				return dropped, nil
+			default:
+			}
+
+			select {
+			case <-q.lines:
+				dropped++
+			default:
+			}
+		}
+	default:
+		select {
+		case q.lines <- line:
+			return 0, nil
+		default:
+			return 1, errQueueFull
+		}
+	}
+}
+
+// runRetryQueue writes the queued lines to the TCP connection, backing off
+// as the retry policy allows while the writes fail, until the queue is stopped.
+// The lines which exhaust the retry policy are dropped
+func (logger *Logger) runRetryQueue(q *retryQueue) {
+	defer close(q.done)
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case line := <-q.lines:
+			for attempt := 1; ; attempt++ {
+				logger.mu.Lock()
+				_, err := logger.writeConn(line, time.Time{})
+				backoff, retry := logger.retryPolicy().NextBackoff(attempt)
+				logger.mu.Unlock()
+
+				if err == nil {
+					atomic.AddUint64(&logger.sent, 1)
+					break
+				}
+
+				if !retry {
+					atomic.AddUint64(&logger.dropped, 1)
+					logger.errorf("dropped a queued line after %d attempts: %v", attempt, err)
+					break
+				}
+
+				select {
+				case <-q.stop:
+					q.held = line
+					return
+				case <-logger.after(backoff):
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package le_go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryQueueRetriesFailedWrites(t *testing.T) {
+	// the backoffs of 10 failed writes add up to about 10s,
+	// the fake clock skips them
+	conn := &fakeConnection{failWrites: 11}
+	clock := newFakeClock()
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial(), clock: clock}
+	defer le.Close()
+
+	le.SetRetryQueue(10, DropNewest)
+
+	if _, err := le.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(conn.Written()) == 0 && time.Now().Before(deadline) {
+		clock.Advance(maxBackoff)
+		time.Sleep(time.Millisecond)
+	}
+
+	writes := conn.Written()
+	if len(writes) != 1 || string(writes[0]) != "myToken  test\n" {
+		t.Fatalf("unexpected writes %q", writes)
+	}
+}
+
+func TestRetryQueueOverflowPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy OverflowPolicy
+		want   string
+	}{
+		{DropNewest, "myToken  2\n"},
+		{DropOldest, "myToken  3\n"},
+	} {
+		conn := &fakeConnection{failWrites: 1000}
+		le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+
+		le.SetRetryQueue(1, tt.policy)
+
+		// wait for the worker to pick the first line, it keeps retrying it
+		le.Write([]byte("1"))
+		for le.Stats().QueueDepth != 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		le.Write([]byte("2"))
+		le.Write([]byte("3"))
+
+		stats := le.Stats()
+		if stats.QueueDepth != 1 || stats.Dropped != 1 {
+			t.Errorf("policy %d: unexpected stats %+v", tt.policy, stats)
+		}
+
+		if line := <-le.queue.lines; string(line) != tt.want {
+			t.Errorf("policy %d: expected %q, got %q", tt.policy, tt.want, line)
+		}
+
+		le.Close()
+	}
+}
+
+func TestRetryQueueBlockPolicy(t *testing.T) {
+	conn := &fakeConnection{failWrites: 1000}
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+	defer le.Close()
+
+	le.SetRetryQueue(1, Block)
+
+	// the worker holds the first line, the second one fills the queue
+	le.Write([]byte("1"))
+	le.Write([]byte("2"))
+
+	blocked := make(chan error)
+	go func() {
+		_, err := le.Write([]byte("3"))
+		blocked <- err
+	}()
+
+	select {
+	case <-blocked:
+		t.Fatal("expected the write to block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	conn.mu.Lock()
+	conn.failWrites = 0
+	conn.mu.Unlock()
+
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(conn.Written()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if writes := conn.Written(); len(writes) != 3 {
+		t.Fatalf("expected 3 writes, got %q", writes)
+	}
+}
+
+func TestRetryQueueBlockPolicyUnblocksOnClose(t *testing.T) {
+	conn := &fakeConnection{failWrites: 1000}
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+
+	le.SetRetryQueue(1, Block)
+
+	le.Write([]byte("1"))
+	le.Write([]byte("2"))
+
+	blocked := make(chan error)
+	go func() {
+		_, err := le.Write([]byte("3"))
+		blocked <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	le.Close()
+
+	select {
+	case err := <-blocked:
+		if err == nil {
+			t.Fatal("expected the blocked write to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Close to unblock the write")
+	}
+}
+
+func TestSetRetryQueueAfterClose(t *testing.T) {
+	le := Logger{conn: &fakeConnection{}, token: "myToken"}
+	le.Close()
+
+	le.SetRetryQueue(1, Block)
+
+	if le.queue != nil {
+		t.Fatal("expected no queue after Close")
+	}
+}
@@ -0,0 +1,60 @@
+package le_go
+
+import (
+	"errors"
+	"time"
+)
+
+var errRateLimited = errors.New("le_go: rate limit exceeded")
+
+// tokenBucket allows rate events per second on average,
+// with bursts of up to burst events
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket at now,
+// it returns false if the bucket is empty
+func (b *tokenBucket) allow(now time.Time) bool {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
+
+// SetRateLimit limits Output to perSecond messages per second on average,
+// with bursts of up to burst messages. Messages over the limit are dropped,
+// counted in Stats, and Output returns an error.
+// A rate <= 0 removes the limit.
+func (logger *Logger) SetRateLimit(perSecond float64, burst int) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if perSecond <= 0 {
+		logger.rateLimit = nil
+		return
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	logger.rateLimit = &tokenBucket{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
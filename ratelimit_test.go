@@ -0,0 +1,58 @@
+package le_go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetRateLimit(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	// the bucket refills a token every 1000s, so only the burst is written
+	le.SetRateLimit(0.001, 3)
+
+	for i := 0; i < 10; i++ {
+		err := le.Print("test")
+
+		if i < 3 && err != nil {
+			t.Fatalf("expected message %d to be written, got %v", i, err)
+		}
+
+		if i >= 3 && err != errRateLimited {
+			t.Fatalf("expected message %d to be dropped, got %v", i, err)
+		}
+	}
+
+	if writes := conn.Written(); len(writes) != 3 {
+		t.Fatalf("expected 3 writes, got %d", len(writes))
+	}
+
+	if dropped := le.Stats().Dropped; dropped != 7 {
+		t.Fatalf("expected 7 dropped messages, got %d", dropped)
+	}
+
+	le.SetRateLimit(0, 0)
+
+	if err := le.Print("test"); err != nil {
+		t.Fatalf("expected no limit, got %v", err)
+	}
+}
+
+func TestTokenBucketRefills(t *testing.T) {
+	now := time.Now()
+	b := &tokenBucket{rate: 10, burst: 2, tokens: 2}
+
+	if !b.allow(now) || !b.allow(now) || b.allow(now) {
+		t.Fatal("expected the burst to be allowed")
+	}
+
+	if !b.allow(now.Add(100*time.Millisecond)) || b.allow(now.Add(100*time.Millisecond)) {
+		t.Fatal("expected a token after 100ms")
+	}
+
+	if !b.allow(now.Add(time.Hour)) || !b.allow(now.Add(time.Hour)) || b.allow(now.Add(time.Hour)) {
+		t.Fatal("expected the tokens to be capped at the burst")
+	}
+}
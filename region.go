@@ -0,0 +1,48 @@
+package le_go
+
+import "fmt"
+
+// Region identifies a Logentries / InsightOps data center
+type Region string
+
+// the supported regions
+const (
+	// RegionLogentries is the legacy logentries.com endpoint
+	RegionLogentries Region = "logentries"
+	RegionUS         Region = "us"
+	RegionEU         Region = "eu"
+	RegionCA         Region = "ca"
+	RegionAU         Region = "au"
+	RegionAP         Region = "ap"
+)
+
+// the TLS token based TCP endpoints of the regions
+var regionHosts = map[Region]string{
+	RegionLogentries: "data.logentries.com:443",
+	RegionUS:         "us.data.logs.insight.rapid7.com:443",
+	RegionEU:         "eu.data.logs.insight.rapid7.com:443",
+	RegionCA:         "ca.data.logs.insight.rapid7.com:443",
+	RegionAU:         "au.data.logs.insight.rapid7.com:443",
+	RegionAP:         "ap.data.logs.insight.rapid7.com:443",
+}
+
+// Host returns the host:port of the region data endpoint
+func (r Region) Host() (string, error) {
+	host, ok := regionHosts[r]
+	if !ok {
+		return "", fmt.Errorf("le_go: unknown region %q", string(r))
+	}
+
+	return host, nil
+}
+
+// ConnectRegion is same as Connect() but connects to the data endpoint
+// of region
+func ConnectRegion(region Region, token string) (*Logger, error) {
+	host, err := region.Host()
+	if err != nil {
+		return nil, err
+	}
+
+	return ConnectHosts(token, host)
+}
@@ -0,0 +1,106 @@
+package le_go
+
+import "time"
+
+const (
+	// the backoff boundaries of the default retry policy
+	minBackoff = 10 * time.Millisecond
+	maxBackoff = 10 * time.Second
+)
+
+// RetryPolicy decides whether and when a failed write is retried
+type RetryPolicy interface {
+	// NextBackoff returns the wait before the retry number attempt,
+	// counting from 1, or false to give up
+	NextBackoff(attempt int) (time.Duration, bool)
+}
+
+// NoRetry is a RetryPolicy which never retries, a failed write is handed
+// to the spool, the retry queue or the fallback right away
+var NoRetry RetryPolicy = noRetry{}
+
+type noRetry struct{}
+
+func (noRetry) NextBackoff(attempt int) (time.Duration, bool) { return 0, false }
+
+// ConstantBackoff is a RetryPolicy which waits Delay before every retry,
+// up to MaxRetries times, 0 means no limit
+type ConstantBackoff struct {
+	Delay      time.Duration
+	MaxRetries int
+}
+
+// NextBackoff implements RetryPolicy
+func (b ConstantBackoff) NextBackoff(attempt int) (time.Duration, bool) {
+	if b.MaxRetries > 0 && attempt > b.MaxRetries {
+		return 0, false
+	}
+
+	return b.Delay, true
+}
+
+// ExponentialBackoff is a RetryPolicy which waits Initial before the first
+// retry and doubles the wait with every retry up to Max, 0 means no
+// maximum. It retries up to MaxRetries times, 0 means no limit
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	MaxRetries int
+}
+
+// NextBackoff implements RetryPolicy
+func (b ExponentialBackoff) NextBackoff(attempt int) (time.Duration, bool) {
+	if b.MaxRetries > 0 && attempt > b.MaxRetries {
+		return 0, false
+	}
+
+	backoff := b.Initial
+	for i := 1; i < attempt; i++ {
+		if backoff *= 2; b.Max > 0 && backoff >= b.Max {
+			return b.Max, true
+		}
+	}
+
+	if b.Max > 0 && backoff > b.Max {
+		backoff = b.Max
+	}
+
+	return backoff, true
+}
+
+// defaultRetryPolicy retries without limit, backing off from 10ms to 10s
+var defaultRetryPolicy RetryPolicy = ExponentialBackoff{
+	Initial: minBackoff,
+	Max:     maxBackoff,
+}
+
+// WithRetryPolicy sets the retry policy of the logger, see SetRetryPolicy()
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(logger *Logger) {
+		logger.retry = policy
+	}
+}
+
+// SetRetryPolicy sets the policy retrying the failed writes, both of the
+// messages and of the lines in the retry queue, the lines which exhaust it
+// in the queue are dropped. A write is attempted once plus the retries the
+// policy allows, each retry reconnects first, since the connection may have
+// been dropped while idle, unless the connection was passed by the user.
+// The default policy retries without limit, backing off exponentially
+// from 10ms to 10s, nil restores it.
+func (logger *Logger) SetRetryPolicy(policy RetryPolicy) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.retry = policy
+}
+
+// retryPolicy returns the retry policy of the logger,
+// the logger lock must be held
+func (logger *Logger) retryPolicy() RetryPolicy {
+	if logger.retry == nil {
+		return defaultRetryPolicy
+	}
+
+	return logger.retry
+}
@@ -0,0 +1,97 @@
+package le_go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	policy := ExponentialBackoff{Initial: time.Second, Max: 5 * time.Second, MaxRetries: 5}
+
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if backoff, ok := policy.NextBackoff(attempt + 1); !ok || backoff != want {
+			t.Fatalf("attempt %d: expected %v, got %v, %v", attempt+1, want, backoff, ok)
+		}
+	}
+
+	if _, ok := policy.NextBackoff(6); ok {
+		t.Fatal("expected no retry after MaxRetries")
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	// a write is attempted once plus MaxRetries times
+	for _, tt := range []struct {
+		failWrites int
+		wantErr    bool
+	}{
+		{2, false},
+		{3, true},
+		{10, true},
+	} {
+		conn := &fakeConnection{failWrites: tt.failWrites}
+		le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+
+		le.SetRetryPolicy(ConstantBackoff{MaxRetries: 2})
+
+		if err := le.Print("test"); (err != nil) != tt.wantErr {
+			t.Errorf("%d failed writes: unexpected error %v", tt.failWrites, err)
+		}
+
+		conn.mu.Lock()
+		attempts := tt.failWrites - conn.failWrites
+		conn.mu.Unlock()
+
+		if attempts += len(conn.Written()); attempts != 3 {
+			t.Errorf("%d failed writes: expected 3 attempts, got %d", tt.failWrites, attempts)
+		}
+
+		le.Close()
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	conn := &fakeConnection{failWrites: 1}
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+	defer le.Close()
+
+	le.SetRetryPolicy(NoRetry)
+
+	if err := le.Print("1"); err == nil {
+		t.Fatal("expected the write not to be retried")
+	}
+
+	// the next write reconnects
+	if err := le.Print("2"); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := conn.Written()
+	if len(writes) != 1 || string(writes[0]) != "myToken  2\n" {
+		t.Fatalf("unexpected writes %q", writes)
+	}
+}
+
+func TestFailedWriteKeepsUserConnOpen(t *testing.T) {
+	conn := &fakeConnection{failWrites: 1}
+	le := NewWithConn(conn, "myToken")
+	defer le.Close()
+
+	le.SetRetryPolicy(NoRetry)
+
+	if err := le.Print("1"); err == nil {
+		t.Fatal("expected the write not to be retried")
+	}
+
+	if err := le.Print("2"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.mu.Lock()
+	closed := conn.closed
+	conn.mu.Unlock()
+
+	if closed || len(conn.Written()) != 1 {
+		t.Fatalf("expected the connection to stay open, got %q", conn.Written())
+	}
+}
@@ -0,0 +1,40 @@
+package le_go
+
+import (
+	"math/rand"
+	"time"
+)
+
+// sampler keeps a random fraction of the messages
+type sampler struct {
+	rate float64
+	keep Severity
+	rand *rand.Rand
+}
+
+// sampled returns if a message logged with severity is kept
+func (s *sampler) sampled(severity Severity) bool {
+	return severity <= s.keep || s.rand.Float64() < s.rate
+}
+
+// SetSampling keeps a random fraction rate, in [0, 1], of the messages
+// logged by Output, the others are discarded before they are written and
+// Output returns no error. The messages logged with keep or a more severe
+// severity are always written, e.g. SeverityError keeps the errors.
+// A rate >= 1 disables sampling.
+func (logger *Logger) SetSampling(rate float64, keep Severity) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if rate >= 1 {
+		logger.sampler = nil
+		return
+	}
+
+	if logger.sampler == nil {
+		logger.sampler = &sampler{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	}
+
+	logger.sampler.rate = rate
+	logger.sampler.keep = keep
+}
@@ -0,0 +1,34 @@
+package le_go
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSetSampling(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetSampling(0.1, SeverityError)
+	le.sampler.rand = rand.New(rand.NewSource(1))
+
+	for i := 0; i < 10000; i++ {
+		if err := le.Print("test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sampled := len(conn.Written())
+	if sampled < 900 || sampled > 1100 {
+		t.Fatalf("expected about 1000 writes, got %d", sampled)
+	}
+
+	for i := 0; i < 100; i++ {
+		le.OutputSeverity(1, SeverityError, "failed")
+	}
+
+	if n := len(conn.Written()) - sampled; n != 100 {
+		t.Fatalf("expected the errors not to be sampled, got %d writes", n)
+	}
+}
@@ -0,0 +1,165 @@
+package le_go
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Severity is the severity of a message,
+// the values are the RFC5424 syslog severities
+type Severity int
+
+// the severities, from the most to the least severe
+const (
+	SeverityEmergency Severity = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+var severityNames = [...]string{
+	SeverityEmergency: "EMERGENCY",
+	SeverityAlert:     "ALERT",
+	SeverityCritical:  "CRITICAL",
+	SeverityError:     "ERROR",
+	SeverityWarning:   "WARNING",
+	SeverityNotice:    "NOTICE",
+	SeverityInfo:      "INFO",
+	SeverityDebug:     "DEBUG",
+}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return "UNKNOWN"
+	}
+
+	return severityNames[s]
+}
+
+// SeverityPosition is the position of the severity in the header
+type SeverityPosition int
+
+// the severity positions, see SetHeaderSeverity()
+const (
+	// SeverityHidden doesn't add the severity to the header
+	SeverityHidden SeverityPosition = iota
+	// SeverityBeforeTimestamp adds the severity between the prefix and the timestamp
+	SeverityBeforeTimestamp
+	// SeverityAfterTimestamp adds the severity after the timestamp
+	SeverityAfterTimestamp
+)
+
+// SetHeaderSeverity adds the severity of the messages, e.g. "WARNING", to
+// the header at position, in lower case if lowercase is set.
+// Messages logged by Print, Write and the like have SeverityInfo.
+// It is hidden by default and ignored by the syslog and JSON formats.
+func (logger *Logger) SetHeaderSeverity(position SeverityPosition, lowercase bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.severityPosition = position
+	logger.severityLowercase = lowercase
+}
+
+// headerSeverity returns the severity token added to the header at position,
+// the logger lock must be held
+func (logger *Logger) headerSeverity(severity Severity, position SeverityPosition) string {
+	if logger.severityPosition != position {
+		return ""
+	}
+
+	if logger.severityLowercase {
+		return strings.ToLower(logger.severityLabel(severity)) + " "
+	}
+
+	return logger.severityLabel(severity) + " "
+}
+
+// SetSeverityLabels sets the labels of the severities in the header and
+// in the JSON format, e.g. "SEVERE" for SeverityError, so that the level
+// detected by Logentries from them is the intended one. The severities
+// missing from labels keep their default label, which is a keyword
+// Logentries recognizes, such as "ERROR". nil restores the defaults.
+func (logger *Logger) SetSeverityLabels(labels map[Severity]string) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.severityLabels = nil
+	if len(labels) == 0 {
+		return
+	}
+
+	logger.severityLabels = make(map[Severity]string, len(labels))
+	for severity, label := range labels {
+		logger.severityLabels[severity] = label
+	}
+}
+
+// severityLabel returns the label of severity,
+// the logger lock must be held
+func (logger *Logger) severityLabel(severity Severity) string {
+	if label, ok := logger.severityLabels[severity]; ok {
+		return label
+	}
+
+	return severity.String()
+}
+
+// severityPrefix is a message prefix which tells the message severity
+type severityPrefix struct {
+	prefix   string
+	severity Severity
+}
+
+// SetSeverityPrefixes detects the severity of the messages logged by
+// Output, Print and the like from their beginning, e.g. with
+// {"ERROR:": SeverityError, "[WARN]": SeverityWarning} "ERROR: boom" is
+// logged with SeverityError as if by OutputSeverity. The longest matching
+// prefix wins, the prefix stays in the message. Messages without one keep
+// SeverityInfo. nil disables the detection, which is the default.
+func (logger *Logger) SetSeverityPrefixes(prefixes map[string]Severity) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.severityPrefixes = nil
+	for prefix, severity := range prefixes {
+		if prefix != "" {
+			logger.severityPrefixes = append(logger.severityPrefixes, severityPrefix{prefix, severity})
+		}
+	}
+
+	sort.Slice(logger.severityPrefixes, func(i, j int) bool {
+		return len(logger.severityPrefixes[i].prefix) > len(logger.severityPrefixes[j].prefix)
+	})
+
+	detect := int32(0)
+	if len(logger.severityPrefixes) > 0 {
+		detect = 1
+	}
+	atomic.StoreInt32(&logger.detectSeverity, detect)
+}
+
+// severityOf returns the severity detected from the beginning of s,
+// SeverityInfo if the detection is disabled or no prefix matches
+func (logger *Logger) severityOf(s string) Severity {
+	// the lock is taken only if the detection is enabled
+	if atomic.LoadInt32(&logger.detectSeverity) == 0 {
+		return SeverityInfo
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	for _, p := range logger.severityPrefixes {
+		if strings.HasPrefix(s, p.prefix) {
+			return p.severity
+		}
+	}
+
+	return SeverityInfo
+}
@@ -0,0 +1,149 @@
+package le_go
+
+import (
+	"log"
+	"regexp"
+	"testing"
+)
+
+func TestSetHeaderSeverity(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.SetFlags(log.Ldate | log.Ltime)
+	le.SetHeaderSeverity(SeverityBeforeTimestamp, false)
+	le.OutputSeverity(1, SeverityWarning, "warned")
+
+	le.SetHeaderSeverity(SeverityAfterTimestamp, true)
+	le.Print("printed")
+
+	le.SetHeaderSeverity(SeverityHidden, false)
+	le.Print("hidden")
+
+	tests := []string{
+		`^myToken myPrefix WARNING \d{4}/\d\d/\d\d \d\d:\d\d:\d\d warned\n$`,
+		`^myToken myPrefix \d{4}/\d\d/\d\d \d\d:\d\d:\d\d info printed\n$`,
+		`^myToken myPrefix \d{4}/\d\d/\d\d \d\d:\d\d:\d\d hidden\n$`,
+	}
+
+	writes := conn.Written()
+	if len(writes) != len(tests) {
+		t.Fatalf("expected %d writes, got %d", len(tests), len(writes))
+	}
+
+	for i, pattern := range tests {
+		if !regexp.MustCompile(pattern).Match(writes[i]) {
+			t.Errorf("expected %q to match %q", writes[i], pattern)
+		}
+	}
+}
+
+func TestSetSeverityToken(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetSeverityToken(SeverityError, "errorToken")
+
+	le.Print("printed")
+	le.OutputSeverity(1, SeverityError, "failed")
+
+	le.SetSeverityToken(SeverityError, "")
+	le.OutputSeverity(1, SeverityError, "failed")
+
+	want := []string{
+		"myToken  printed\n",
+		"errorToken  failed\n",
+		"myToken  failed\n",
+	}
+
+	writes := conn.Written()
+	if len(writes) != len(want) {
+		t.Fatalf("expected %d writes, got %q", len(want), writes)
+	}
+
+	for i := range want {
+		if string(writes[i]) != want[i] {
+			t.Errorf("expected %q, got %q", want[i], writes[i])
+		}
+	}
+}
+
+func TestSetSeverityLabels(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.SetHeaderSeverity(SeverityBeforeTimestamp, false)
+	le.SetSeverityLabels(map[Severity]string{SeverityError: "SEVERE"})
+
+	le.OutputSeverity(1, SeverityError, "failed")
+	le.OutputSeverity(1, SeverityWarning, "warned")
+
+	le.SetJSONFormat(true)
+	le.OutputSeverity(1, SeverityError, "failed")
+
+	le.SetSeverityLabels(nil)
+	le.OutputSeverity(1, SeverityError, "failed")
+
+	want := []string{
+		"myToken myPrefix SEVERE failed\n",
+		"myToken myPrefix WARNING warned\n",
+		`myToken {"severity":"SEVERE","prefix":"myPrefix","message":"failed"}` + "\n",
+		`myToken {"severity":"ERROR","prefix":"myPrefix","message":"failed"}` + "\n",
+	}
+
+	writes := conn.Written()
+	if len(writes) != len(want) {
+		t.Fatalf("expected %d writes, got %q", len(want), writes)
+	}
+
+	for i := range want {
+		if string(writes[i]) != want[i] {
+			t.Errorf("expected %q, got %q", want[i], writes[i])
+		}
+	}
+}
+
+func TestSetSeverityPrefixes(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	if sev := le.severityOf("ERROR: boom"); sev != SeverityInfo {
+		t.Fatalf("expected no detection by default, got %v", sev)
+	}
+
+	le.SetHeaderSeverity(SeverityBeforeTimestamp, false)
+	le.SetSeverityPrefixes(map[string]Severity{
+		"ERR":    SeverityWarning,
+		"ERROR:": SeverityError,
+		"[WARN]": SeverityWarning,
+	})
+
+	if sev := le.severityOf("ERROR: boom"); sev != SeverityError {
+		t.Fatalf("expected SeverityError, got %v", sev)
+	}
+
+	le.Print("ERROR: boom")
+	le.Print("[WARN] low disk")
+	le.Print("started")
+
+	want := []string{
+		"myToken myPrefix ERROR ERROR: boom\n",
+		"myToken myPrefix WARNING [WARN] low disk\n",
+		"myToken myPrefix INFO started\n",
+	}
+
+	writes := conn.Written()
+	if len(writes) != len(want) {
+		t.Fatalf("expected %d writes, got %q", len(want), writes)
+	}
+
+	for i := range want {
+		if string(writes[i]) != want[i] {
+			t.Fatalf("expected %q, got %q", want[i], writes[i])
+		}
+	}
+}
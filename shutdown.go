@@ -0,0 +1,50 @@
+package le_go
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// InstallShutdownHandler drains l, see Drain(), when the process receives
+// SIGINT or SIGTERM, so the messages pending in ordered mode or written from
+// goroutines aren't lost when e.g. a container is stopped. It waits up to
+// timeout for them, closes l and raises the signal again, which terminates
+// the process unless the application handles the signal itself.
+// It is opt-in, the signals are left alone if it isn't called.
+func InstallShutdownHandler(l *Logger, timeout time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go l.handleShutdown(signals, timeout, raise)
+}
+
+// handleShutdown drains the logger once a signal is received on signals,
+// then stops the delivery to signals and passes the signal to raise
+func (logger *Logger) handleShutdown(signals chan os.Signal, timeout time.Duration, raise func(sig os.Signal)) {
+	sig := <-signals
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := logger.Drain(ctx); err != nil {
+		logger.errorf("shutting down on %v: %v", sig, err)
+	}
+
+	signal.Stop(signals)
+	raise(sig)
+}
+
+// raise sends sig to the process, the process exits if it can't be sent
+func raise(sig os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,42 @@
+package le_go
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleShutdown(t *testing.T) {
+	conn := &fakeConnection{delay: 10 * time.Millisecond}
+	le := Logger{conn: conn, token: "myToken"}
+
+	le.SetConcurrentWrites(5)
+
+	for i := 0; i < 5; i++ {
+		le.Print(i)
+	}
+
+	signals := make(chan os.Signal, 1)
+	raised := make(chan os.Signal, 1)
+	go le.handleShutdown(signals, time.Second, func(sig os.Signal) { raised <- sig })
+
+	signals <- syscall.SIGTERM
+
+	select {
+	case sig := <-raised:
+		if sig != syscall.SIGTERM {
+			t.Fatalf("expected SIGTERM to be raised again, got %v", sig)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the signal to be raised again")
+	}
+
+	if len(conn.Written()) != 5 {
+		t.Fatalf("expected the 5 pending messages to be written, got %d", len(conn.Written()))
+	}
+
+	if !conn.closed {
+		t.Fatal("expected the connection to be closed")
+	}
+}
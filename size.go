@@ -0,0 +1,96 @@
+package le_go
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+var errMessageTooLarge = errors.New("le_go: message exceeds the maximum message size")
+
+// OversizePolicy decides what happens to a message larger than the
+// maximum message size, see SetMaxMessageSize()
+type OversizePolicy int
+
+const (
+	// TruncateOversized cuts the message to the maximum size,
+	// ending it with a marker telling how many bytes were cut
+	TruncateOversized OversizePolicy = iota
+	// DropOversized discards the message, it is counted in Stats.Dropped
+	DropOversized
+)
+
+// SetMaxMessageSize limits the size in bytes of a single message, e.g. to
+// keep a pathological message from being split into thousands of lines
+// and monopolizing the connection. Unlike the maximum line length, which
+// splits longer messages, a message above n is truncated or dropped as
+// policy decides. n <= 0 removes the limit.
+func (logger *Logger) SetMaxMessageSize(n int, policy OversizePolicy) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.maxMessageSize = n
+	logger.oversizePolicy = policy
+}
+
+// limitSize applies the maximum message size to p, it returns
+// errMessageTooLarge if p is dropped. p isn't modified.
+// the logger lock must be held
+func (logger *Logger) limitSize(p []byte) ([]byte, error) {
+	max := logger.maxMessageSize
+	if max <= 0 || len(p) <= max {
+		return p, nil
+	}
+
+	if logger.oversizePolicy == DropOversized {
+		atomic.AddUint64(&logger.dropped, 1)
+		return nil, errMessageTooLarge
+	}
+
+	cut, marker := truncation(len(p), max)
+
+	// the cut doesn't split a multi-byte character
+	for cut > 0 && !utf8.RuneStart(p[cut]) {
+		cut--
+	}
+
+	return append(p[:cut:cut], marker...), nil
+}
+
+// limitSizeString is same as limitSize() for a string, it is checked
+// before the message is formatted or copied. reserved bytes of the
+// maximum size are kept for what is added to s, e.g. its file and line.
+// the logger lock must be held
+func (logger *Logger) limitSizeString(s string, reserved int) (string, error) {
+	max := logger.maxMessageSize - reserved
+	if logger.maxMessageSize <= 0 || len(s) <= max {
+		return s, nil
+	}
+
+	if logger.oversizePolicy == DropOversized {
+		atomic.AddUint64(&logger.dropped, 1)
+		return "", errMessageTooLarge
+	}
+
+	cut, marker := truncation(len(s), max)
+
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return s[:cut] + marker, nil
+}
+
+// truncation returns how many bytes of a message of n bytes are kept to
+// truncate it to max bytes and the marker ending it
+func truncation(n, max int) (int, string) {
+	marker := "... [truncated " + strconv.Itoa(n) + " bytes]"
+
+	cut := max - len(marker)
+	if cut < 0 {
+		cut = 0
+	}
+
+	return cut, marker
+}
@@ -0,0 +1,73 @@
+package le_go
+
+import (
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSetMaxMessageSize(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetMaxMessageSize(40, TruncateOversized)
+
+	le.WriteRaw([]byte("short"))
+	le.WriteRaw([]byte(strings.Repeat("a", 100)))
+
+	writes := conn.Written()
+	if len(writes) != 2 || string(writes[0]) != "myToken short\n" {
+		t.Fatalf("expected 2 writes, got %q", writes)
+	}
+
+	want := "myToken " + strings.Repeat("a", 15) + "... [truncated 100 bytes]\n"
+	if string(writes[1]) != want {
+		t.Fatalf("expected %q, got %q", want, writes[1])
+	}
+
+	le.SetMaxMessageSize(40, DropOversized)
+
+	if _, err := le.WriteRaw([]byte(strings.Repeat("a", 100))); err != errMessageTooLarge {
+		t.Fatalf("expected errMessageTooLarge, got %v", err)
+	}
+
+	if len(conn.Written()) != 2 {
+		t.Fatalf("expected the oversized message to be dropped, got %q", conn.Written())
+	}
+
+	if dropped := le.Stats().Dropped; dropped != 1 {
+		t.Fatalf("expected 1 dropped message, got %d", dropped)
+	}
+}
+
+func TestMaxMessageSizeBeforeEntry(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetFlags(log.Lshortfile)
+	le.SetMaxMessageSize(60, TruncateOversized)
+
+	_, _, line, _ := runtime.Caller(0)
+	le.Print(strings.Repeat("a", 100))
+
+	// the message is truncated once, leaving room for its file and line
+	location := "size_test.go:" + strconv.Itoa(line+1) + ": "
+	want := "myToken  " + location + strings.Repeat("a", 60-len(location)-25) + "... [truncated 100 bytes]\n"
+	if writes := conn.Written(); len(writes) != 1 || string(writes[0]) != want {
+		t.Fatalf("expected %q, got %q", want, writes)
+	}
+
+	le.SetMaxMessageSize(60, DropOversized)
+
+	if err := le.Output(1, strings.Repeat("a", 100)); err != errMessageTooLarge {
+		t.Fatalf("expected errMessageTooLarge, got %v", err)
+	}
+
+	if dropped := le.Stats().Dropped; dropped != 1 {
+		t.Fatalf("expected 1 dropped message, got %d", dropped)
+	}
+}
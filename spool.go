@@ -0,0 +1,243 @@
+package le_go
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what happens to a log line when a bounded
+// buffer is full
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the line which doesn't fit
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest buffered lines to make room
+	DropOldest
+	// Block waits until there is room for the line,
+	// the spool doesn't block and treats it as DropNewest
+	Block
+)
+
+const (
+	spoolFileName = "le_go.spool"
+
+	// the interval in which the spool is replayed in the background
+	spoolInterval = time.Second
+
+	// the size of the record length header in the spool file
+	spoolHeaderLen = 4
+)
+
+var errSpoolFull = errors.New("le_go: spool is full")
+
+// spool is an append-only file holding log lines which couldn't be
+// delivered, every line is stored as a length prefixed record
+type spool struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	policy  OverflowPolicy
+
+	// records is the number of spooled lines,
+	// it avoids touching the file system when the spool is empty
+	records int
+}
+
+// newSpool creates a spool inside dir, creating dir if needed
+func newSpool(dir string, maxSize int64, policy OverflowPolicy) (*spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	s := &spool{
+		path:    filepath.Join(dir, spoolFileName),
+		maxSize: maxSize,
+		policy:  policy,
+	}
+
+	// lines left over by a previous process are replayed too
+	lines, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.records = len(lines)
+
+	return s, nil
+}
+
+// append adds a line to the end of the spool, applying the overflow policy
+// if the spool would grow beyond its maximum size
+func (s *spool) append(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recordLen := int64(spoolHeaderLen + len(line))
+
+	if s.maxSize > 0 {
+		if recordLen > s.maxSize {
+			return errSpoolFull
+		}
+
+		size, err := s.size()
+		if err != nil {
+			return err
+		}
+
+		if size+recordLen > s.maxSize {
+			if s.policy != DropOldest {
+				return errSpoolFull
+			}
+
+			if err := s.dropOldest(size + recordLen - s.maxSize); err != nil {
+				return err
+			}
+		}
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	var header [spoolHeaderLen]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(line)))
+
+	_, err = f.Write(append(header[:], line...))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		s.records++
+	}
+
+	return err
+}
+
+// empty returns if the spool has no pending lines
+func (s *spool) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records == 0
+}
+
+// replay calls write for every spooled line in FIFO order,
+// delivered lines are removed from the spool.
+// it stops at the first failed write, keeping the remaining lines
+func (s *spool) replay(write func([]byte) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	for i, line := range lines {
+		if err := write(line); err != nil {
+			if rewriteErr := s.rewrite(lines[i:]); rewriteErr != nil {
+				return rewriteErr
+			}
+
+			return err
+		}
+	}
+
+	return s.rewrite(nil)
+}
+
+// dropOldest removes lines from the head of the spool until at least
+// n bytes were freed
+func (s *spool) dropOldest(n int64) error {
+	lines, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	var freed int64
+	for len(lines) > 0 && freed < n {
+		freed += int64(spoolHeaderLen + len(lines[0]))
+		lines = lines[1:]
+	}
+
+	return s.rewrite(lines)
+}
+
+// size returns the size of the spool file in bytes
+func (s *spool) size() (int64, error) {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// read returns all the spooled lines,
+// a truncated record left by an interrupted append is removed from the file
+func (s *spool) read() ([][]byte, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		lines [][]byte
+		valid int64
+	)
+	for rest := data; len(rest) > 0; {
+		if len(rest) < spoolHeaderLen {
+			break
+		}
+
+		n := int(binary.BigEndian.Uint32(rest))
+		if len(rest)-spoolHeaderLen < n {
+			break
+		}
+
+		lines = append(lines, rest[spoolHeaderLen:spoolHeaderLen+n])
+		rest = rest[spoolHeaderLen+n:]
+		valid += int64(spoolHeaderLen + n)
+	}
+
+	if valid < int64(len(data)) {
+		if err := os.Truncate(s.path, valid); err != nil {
+			return nil, err
+		}
+	}
+
+	return lines, nil
+}
+
+// rewrite replaces the content of the spool with lines
+func (s *spool) rewrite(lines [][]byte) error {
+	var data []byte
+	for _, line := range lines {
+		var header [spoolHeaderLen]byte
+		binary.BigEndian.PutUint32(header[:], uint32(len(line)))
+
+		data = append(data, header[:]...)
+		data = append(data, line...)
+	}
+
+	if err := ioutil.WriteFile(s.path, data, 0600); err != nil {
+		return err
+	}
+
+	s.records = len(lines)
+
+	return nil
+}
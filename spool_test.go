@@ -0,0 +1,129 @@
+package le_go
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempSpoolDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "le_go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestSpoolReplaysInOrderAfterReconnect(t *testing.T) {
+	dir := tempSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	// the write of 1 and the replay of 1 before 2 fail
+	conn := &fakeConnection{failWrites: 2}
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+	defer le.Close()
+
+	if err := le.SetSpool(dir, 0, DropNewest); err != nil {
+		t.Fatal(err)
+	}
+
+	le.Write([]byte("1"))
+	le.Write([]byte("2"))
+
+	if len(conn.Written()) != 0 {
+		t.Fatal("expected the lines to be spooled")
+	}
+
+	le.Write([]byte("3"))
+
+	writes := conn.Written()
+	if len(writes) != 3 {
+		t.Fatalf("expected 3 writes, got %d", len(writes))
+	}
+
+	for i, want := range []string{"myToken  1\n", "myToken  2\n", "myToken  3\n"} {
+		if string(writes[i]) != want {
+			t.Errorf("write %d: expected %q, got %q", i, want, writes[i])
+		}
+	}
+
+	if !le.spool.empty() {
+		t.Fail()
+	}
+}
+
+func TestSpoolOverflowPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy OverflowPolicy
+		want   string
+	}{
+		{DropNewest, "1"},
+		{DropOldest, "2"},
+	} {
+		dir := tempSpoolDir(t)
+		defer os.RemoveAll(dir)
+
+		s, err := newSpool(dir, spoolHeaderLen+1, tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.append([]byte("1"))
+		s.append([]byte("2"))
+
+		lines, err := s.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(lines) != 1 || string(lines[0]) != tt.want {
+			t.Errorf("policy %d: unexpected spool content %q", tt.policy, lines)
+		}
+	}
+}
+
+func TestSpoolDropsTruncatedRecord(t *testing.T) {
+	dir := tempSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	// a complete record followed by a partial header
+	data := []byte{0, 0, 0, 2, 'o', 'k', 0, 0}
+	if err := ioutil.WriteFile(filepath.Join(dir, spoolFileName), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", dial: conn.redial()}
+	defer le.Close()
+
+	if err := le.SetSpool(dir, 0, DropNewest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := le.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := conn.Written()
+	if len(writes) != 2 || string(writes[0]) != "ok" || string(writes[1]) != "myToken  test\n" {
+		t.Fatalf("unexpected writes %q", writes)
+	}
+
+	if !le.spool.empty() {
+		t.Fatal("expected the spool to be empty")
+	}
+}
+
+func TestSetSpoolAfterClose(t *testing.T) {
+	dir := tempSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	le := Logger{conn: &fakeConnection{}, token: "myToken"}
+	le.Close()
+
+	if err := le.SetSpool(dir, 0, DropNewest); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
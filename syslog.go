@@ -0,0 +1,77 @@
+package le_go
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// the RFC5424 timestamp format
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// SyslogFormat configures the RFC5424 syslog format of the log lines,
+// see SetSyslogFormat()
+type SyslogFormat struct {
+	// Facility is the syslog facility code, e.g. 1 for user-level messages
+	// or 16 to 23 for local0 to local7
+	Facility int
+	// AppName is the APP-NAME field, the logger prefix is used if it is empty
+	AppName string
+}
+
+// syslogFormat is a SyslogFormat with the host name and PID resolved
+type syslogFormat struct {
+	facility int
+	appName  string
+	hostname string
+	pid      string
+}
+
+// header returns the syslog header of a message logged with severity at now,
+// without the structured data
+func (f *syslogFormat) header(severity Severity, prefix string, now time.Time) string {
+	app := f.appName
+	if app == "" {
+		app = prefix
+	}
+
+	pri := f.facility*8 + int(severity)
+
+	return "<" + strconv.Itoa(pri) + ">1 " + now.Format(syslogTimeFormat) + " " +
+		nilValue(f.hostname) + " " + nilValue(app) + " " + f.pid + " - - "
+}
+
+// nilValue returns s or the syslog NILVALUE if s is empty
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// SetSyslogFormat formats every line as an RFC5424 syslog message,
+// <PRI>1 TIMESTAMP HOST APP PROCID MSGID - MSG,
+// the access token is still written ahead of it.
+// PRI is derived from the facility and the severity of the message,
+// see OutputSeverity(). The host name and PID are resolved once.
+// A nil format restores the default, in which the lines start with the prefix.
+func (logger *Logger) SetSyslogFormat(format *SyslogFormat) {
+	var f *syslogFormat
+
+	if format != nil {
+		hostname, _ := os.Hostname()
+
+		f = &syslogFormat{
+			facility: format.Facility,
+			appName:  format.AppName,
+			hostname: hostname,
+			pid:      strconv.Itoa(os.Getpid()),
+		}
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.syslog = f
+}
@@ -0,0 +1,58 @@
+package le_go
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestSyslogFormat(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken"}
+	defer le.Close()
+
+	le.SetPrefix("myApp")
+	le.SetSyslogFormat(&SyslogFormat{Facility: 16})
+
+	le.OutputSeverity(1, SeverityError, "failed")
+	le.Print("done")
+
+	hostname, _ := os.Hostname()
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []string{
+		`^myToken <131>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) ` +
+			regexp.QuoteMeta(hostname) + ` myApp ` + pid + ` - - failed\n$`,
+		`^myToken <134>1 \S+ \S+ myApp ` + pid + ` - - done\n$`,
+	}
+
+	writes := conn.Written()
+	if len(writes) != len(tests) {
+		t.Fatalf("expected %d writes, got %d", len(tests), len(writes))
+	}
+
+	for i, pattern := range tests {
+		if !regexp.MustCompile(pattern).Match(writes[i]) {
+			t.Errorf("expected %q to match %q", writes[i], pattern)
+		}
+	}
+}
+
+func TestSyslogFormatAppName(t *testing.T) {
+	le := Logger{token: "myToken"}
+	le.SetPrefix("myPrefix")
+	le.SetSyslogFormat(&SyslogFormat{Facility: 1, AppName: "myApp"})
+
+	buf := le.makeBuf(nil, le.header(SeverityDebug), []byte("test"))
+
+	if !regexp.MustCompile(`^myToken <15>1 \S+ \S+ myApp \d+ - - test\n$`).Match(buf) {
+		t.Fatalf("unexpected line %q", buf)
+	}
+
+	le.SetSyslogFormat(nil)
+
+	if buf := le.makeBuf(nil, le.header(SeverityDebug), []byte("test")); string(buf) != "myToken myPrefix test\n" {
+		t.Fatalf("expected the default format, got %q", buf)
+	}
+}
@@ -0,0 +1,109 @@
+package le_go
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// LEpochMillis is a logger flag which replaces the date and time of the
+// header timestamp with the Unix time in milliseconds, see SetFlags()
+const LEpochMillis = 1 << 16
+
+// itoa appends the decimal i to buf, zero padded to wid digits,
+// as done by the standard log package
+func itoa(buf []byte, i int, wid int) []byte {
+	var b [20]byte
+	bp := len(b) - 1
+	for i >= 10 || wid > 1 {
+		wid--
+		q := i / 10
+		b[bp] = byte('0' + i - q*10)
+		bp--
+		i = q
+	}
+	b[bp] = byte('0' + i)
+
+	return append(buf, b[bp:]...)
+}
+
+// timestamped returns if a timestamp is added to the header,
+// the logger lock must be held
+func (logger *Logger) timestamped() bool {
+	return logger.timestampFormat != "" ||
+		logger.flag&(log.Ldate|log.Ltime|log.Lmicroseconds|LEpochMillis) != 0
+}
+
+// appendTimestamp appends the timestamp of t followed by a space to buf,
+// as selected by the Ldate, Ltime, Lmicroseconds, LUTC and LEpochMillis
+// flags and the timestamp format and location. Nothing is appended if no
+// timestamp is enabled.
+// the logger lock must be held
+func (logger *Logger) appendTimestamp(buf []byte, t time.Time) []byte {
+	flag := logger.flag
+
+	if !logger.timestamped() {
+		return buf
+	}
+
+	if flag&LEpochMillis != 0 {
+		return append(strconv.AppendInt(buf, t.UnixNano()/int64(time.Millisecond), 10), ' ')
+	}
+
+	if logger.location != nil {
+		t = t.In(logger.location)
+	} else if flag&log.LUTC != 0 {
+		t = t.UTC()
+	}
+
+	if logger.timestampFormat != "" {
+		return append(t.AppendFormat(buf, logger.timestampFormat), ' ')
+	}
+
+	if flag&log.Ldate != 0 {
+		year, month, day := t.Date()
+		buf = itoa(buf, year, 4)
+		buf = append(buf, '/')
+		buf = itoa(buf, int(month), 2)
+		buf = append(buf, '/')
+		buf = itoa(buf, day, 2)
+		buf = append(buf, ' ')
+	}
+
+	if flag&(log.Ltime|log.Lmicroseconds) != 0 {
+		hour, min, sec := t.Clock()
+		buf = itoa(buf, hour, 2)
+		buf = append(buf, ':')
+		buf = itoa(buf, min, 2)
+		buf = append(buf, ':')
+		buf = itoa(buf, sec, 2)
+		if flag&log.Lmicroseconds != 0 {
+			buf = append(buf, '.')
+			buf = itoa(buf, t.Nanosecond()/1e3, 6)
+		}
+		buf = append(buf, ' ')
+	}
+
+	return buf
+}
+
+// SetTimestampFormat sets the time layout, e.g. time.RFC3339, of the
+// timestamp added to the header after the prefix. It takes precedence over
+// the date and time format of the Ldate, Ltime and Lmicroseconds flags,
+// the location still applies. An empty layout restores the flags format.
+func (logger *Logger) SetTimestampFormat(layout string) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.timestampFormat = layout
+}
+
+// SetTimestampLocation sets the time zone of the timestamp added to the
+// header, e.g. to stamp logs in a business time zone regardless of the host
+// locale. It takes precedence over the LUTC flag, nil restores it.
+func (logger *Logger) SetTimestampLocation(loc *time.Location) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.location = loc
+}
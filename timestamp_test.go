@@ -0,0 +1,120 @@
+package le_go
+
+import (
+	"log"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTimestampFlags(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.SetFlags(log.Ldate | log.Lmicroseconds)
+	le.Print("test")
+
+	writes := conn.Written()
+	if len(writes) != 1 || !regexp.MustCompile(`^myToken myPrefix \d{4}/\d\d/\d\d \d\d:\d\d:\d\d\.\d{6} test\n$`).Match(writes[0]) {
+		t.Fatalf("expected a timestamp, got %q", writes)
+	}
+}
+
+func TestSetTimestampFormat(t *testing.T) {
+	conn := &fakeConnection{}
+	le := Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.SetTimestampFormat(time.RFC3339)
+	le.Print("test")
+
+	writes := conn.Written()
+	if len(writes) != 1 || !regexp.MustCompile(`^myToken myPrefix \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(Z|[+-]\d\d:\d\d) test\n$`).Match(writes[0]) {
+		t.Fatalf("expected an RFC3339 timestamp, got %q", writes)
+	}
+
+	le.SetFlags(log.LUTC)
+	ts := le.appendTimestamp(nil, time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*3600)))
+
+	if string(ts) != "2020-01-02T08:04:05Z " {
+		t.Fatalf("expected the UTC timestamp, got %q", ts)
+	}
+}
+
+func TestSetTimestampLocation(t *testing.T) {
+	le := Logger{}
+	le.SetFlags(log.Ltime | log.LUTC)
+	le.SetTimestampLocation(time.FixedZone("EST", -5*3600))
+
+	ts := le.appendTimestamp(nil, time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	if string(ts) != "10:04:05 " {
+		t.Fatalf("expected the hour in the location, got %q", ts)
+	}
+
+	le.SetTimestampLocation(nil)
+
+	if ts := le.appendTimestamp(nil, time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)); string(ts) != "15:04:05 " {
+		t.Fatalf("expected the UTC hour, got %q", ts)
+	}
+}
+
+func TestEpochMillis(t *testing.T) {
+	le := Logger{}
+	le.SetFlags(LEpochMillis | log.Lshortfile)
+	le.SetTimestampFormat(time.RFC3339)
+
+	ts := le.appendTimestamp(nil, time.Date(2020, 1, 2, 3, 4, 5, 678e6, time.UTC))
+
+	if string(ts) != "1577934245678 " {
+		t.Fatalf("expected the Unix time in milliseconds, got %q", ts)
+	}
+
+	conn := &fakeConnection{}
+	le = Logger{conn: conn, token: "myToken", prefix: "myPrefix"}
+	defer le.Close()
+
+	le.SetFlags(LEpochMillis | log.Lshortfile)
+	le.Print("test")
+
+	writes := conn.Written()
+	if len(writes) != 1 || !regexp.MustCompile(`^myToken myPrefix \d{13} timestamp_test\.go:\d+: test\n$`).Match(writes[0]) {
+		t.Fatalf("expected the Unix time and the caller, got %q", writes)
+	}
+}
+
+func TestJSONFormatTimestamp(t *testing.T) {
+	conn := &fakeConnection{}
+	clock := newFakeClock()
+	le := Logger{conn: conn, token: "myToken", clock: clock}
+	defer le.Close()
+
+	le.SetJSONFormat(true)
+	le.Print("none")
+
+	le.SetTimestampFormat(time.RFC3339)
+	le.SetTimestampLocation(time.UTC)
+	le.Print("formatted")
+
+	le.SetTimestampFormat("")
+	le.SetFlags(LEpochMillis)
+	le.Print("millis")
+
+	want := []string{
+		`myToken {"severity":"INFO","message":"none"}` + "\n",
+		`myToken {"severity":"INFO","time":"2020-01-02T03:04:05Z","message":"formatted"}` + "\n",
+		`myToken {"severity":"INFO","time":1577934245000,"message":"millis"}` + "\n",
+	}
+
+	writes := conn.Written()
+	if len(writes) != len(want) {
+		t.Fatalf("expected %d writes, got %q", len(want), writes)
+	}
+
+	for i := range want {
+		if string(writes[i]) != want[i] {
+			t.Fatalf("expected %q, got %q", want[i], writes[i])
+		}
+	}
+}